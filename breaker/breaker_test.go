@@ -0,0 +1,104 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerStaysClosedBelowThreshold(t *testing.T) {
+	b := New(3, time.Minute)
+	b.Failure()
+	b.Failure()
+	if got := b.CurrentState(); got != Closed {
+		t.Fatalf("state = %s, want closed", got)
+	}
+	if !b.Allow() {
+		t.Fatal("Allow() = false, want true while closed")
+	}
+}
+
+func TestBreakerTripsAtThreshold(t *testing.T) {
+	b := New(3, time.Minute)
+	b.Failure()
+	b.Failure()
+	b.Failure()
+	if got := b.CurrentState(); got != Open {
+		t.Fatalf("state = %s, want open", got)
+	}
+	if b.Allow() {
+		t.Fatal("Allow() = true, want false while open within cooldown")
+	}
+}
+
+func TestBreakerSuccessResetsFailureCount(t *testing.T) {
+	b := New(3, time.Minute)
+	b.Failure()
+	b.Failure()
+	b.Success()
+	b.Failure()
+	b.Failure()
+	if got := b.CurrentState(); got != Closed {
+		t.Fatalf("state = %s, want closed (failure count should have reset)", got)
+	}
+}
+
+func TestBreakerHalfOpenAllowsSingleProbe(t *testing.T) {
+	b := New(1, time.Millisecond)
+	b.Failure()
+	time.Sleep(2 * time.Millisecond)
+
+	if !b.Allow() {
+		t.Fatal("Allow() = false, want true for the first probe after cooldown")
+	}
+	if got := b.CurrentState(); got != HalfOpen {
+		t.Fatalf("state = %s, want half-open", got)
+	}
+	if b.Allow() {
+		t.Fatal("Allow() = true, want false for a second concurrent probe")
+	}
+}
+
+func TestBreakerHalfOpenSuccessCloses(t *testing.T) {
+	b := New(1, time.Millisecond)
+	b.Failure()
+	time.Sleep(2 * time.Millisecond)
+	b.Allow() // transitions to half-open
+	b.Success()
+	if got := b.CurrentState(); got != Closed {
+		t.Fatalf("state = %s, want closed", got)
+	}
+}
+
+func TestBreakerHalfOpenFailureReopens(t *testing.T) {
+	b := New(1, time.Millisecond)
+	b.Failure()
+	time.Sleep(2 * time.Millisecond)
+	b.Allow() // transitions to half-open
+	b.Failure()
+	if got := b.CurrentState(); got != Open {
+		t.Fatalf("state = %s, want open", got)
+	}
+	if b.Allow() {
+		t.Fatal("Allow() = true, want false immediately after re-opening")
+	}
+}
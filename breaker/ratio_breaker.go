@@ -0,0 +1,157 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// outcome is one request's pass/fail recorded within the rolling window.
+type outcome struct {
+	at      time.Time
+	success bool
+}
+
+// RatioBreaker is a rolling-window, failure-ratio circuit breaker: unlike
+// Breaker, which trips after a fixed run of consecutive failures, it trips
+// once at least minVolume requests have landed inside window and the
+// fraction of those that failed is >= failureRatio. That suits a peer server
+// shared by many callers, where a handful of unlucky requests shouldn't
+// trip the breaker but a sustained error rate should.
+type RatioBreaker struct {
+	failureRatio float64
+	minVolume    int
+	window       time.Duration
+	cooldown     time.Duration
+
+	mu       sync.Mutex
+	state    State
+	openedAt time.Time
+	outcomes []outcome
+}
+
+// NewRatio returns a RatioBreaker that trips when at least minVolume
+// requests land within window and failureRatio of them failed, staying open
+// for cooldown before allowing a half-open probe.
+func NewRatio(failureRatio float64, minVolume int, window, cooldown time.Duration) *RatioBreaker {
+	return &RatioBreaker{
+		failureRatio: failureRatio,
+		minVolume:    minVolume,
+		window:       window,
+		cooldown:     cooldown,
+	}
+}
+
+// Allow reports whether a call should be let through. Callers must report
+// the outcome with Success or Failure once the call (and any retries the
+// backoffer ran under this single allowance) completes — Allow is meant to
+// be checked once per logical request, not once per retry attempt, so a
+// half-open probe isn't consumed by the backoffer's own retries.
+func (b *RatioBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Closed:
+		return true
+	case Open:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = HalfOpen
+		return true
+	case HalfOpen:
+		// only a single probe is allowed through at a time
+		return false
+	}
+	return true
+}
+
+// Success reports that the last allowed call (including any retries folded
+// into it) succeeded.
+func (b *RatioBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == HalfOpen {
+		b.state = Closed
+		b.outcomes = nil
+		return
+	}
+	b.record(true)
+}
+
+// Failure reports that the last allowed call failed.
+func (b *RatioBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == HalfOpen {
+		// the probe failed, re-open with a fresh cooldown
+		b.state = Open
+		b.openedAt = time.Now()
+		return
+	}
+
+	b.record(false)
+	if ratio, volume := b.ratioLocked(); volume >= b.minVolume && ratio >= b.failureRatio {
+		b.state = Open
+		b.openedAt = time.Now()
+	}
+}
+
+// record appends an outcome and prunes anything older than window.
+func (b *RatioBreaker) record(success bool) {
+	now := time.Now()
+	b.outcomes = append(b.outcomes, outcome{at: now, success: success})
+	cutoff := now.Add(-b.window)
+	i := 0
+	for ; i < len(b.outcomes); i++ {
+		if b.outcomes[i].at.After(cutoff) {
+			break
+		}
+	}
+	b.outcomes = b.outcomes[i:]
+}
+
+// ratioLocked returns the failure ratio and total volume among the outcomes
+// still inside the window. Callers must hold b.mu.
+func (b *RatioBreaker) ratioLocked() (ratio float64, volume int) {
+	volume = len(b.outcomes)
+	if volume == 0 {
+		return 0, 0
+	}
+	failures := 0
+	for _, o := range b.outcomes {
+		if !o.success {
+			failures++
+		}
+	}
+	return float64(failures) / float64(volume), volume
+}
+
+// CurrentState returns the breaker's current state.
+func (b *RatioBreaker) CurrentState() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -22,11 +22,18 @@ package cluster
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/fsnotify/fsnotify"
+	"github.com/topfreegames/pitaya/breaker"
+	"github.com/topfreegames/pitaya/cluster/retry"
 	"github.com/topfreegames/pitaya/config"
 	"github.com/topfreegames/pitaya/conn/message"
 	"github.com/topfreegames/pitaya/constants"
@@ -38,8 +45,9 @@ import (
 	"github.com/topfreegames/pitaya/protos"
 	"github.com/topfreegames/pitaya/route"
 	"github.com/topfreegames/pitaya/session"
-	"github.com/topfreegames/pitaya/tracing"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 )
 
 // GRPCClient rpc server struct
@@ -53,6 +61,179 @@ type GRPCClient struct {
 	reqTimeout       time.Duration //请求超时
 	server           *Server       //本地服务器
 	metricsReporters []metrics.Reporter
+	tlsConfig        *tlsConfig      // pitaya.cluster.rpc.client.grpc.tls.*
+	backoffer        *retry.Backoffer // pitaya.cluster.rpc.client.grpc.retry.*, nil means no retries
+
+	poolSize            int                      // pitaya.cluster.rpc.client.grpc.pool.size, default 1
+	keepalive           keepalive.ClientParameters // pitaya.cluster.rpc.client.grpc.keepalive.*
+	healthCheckInterval time.Duration            // pitaya.cluster.rpc.client.grpc.healthcheckinterval
+
+	breakerCfg *breakerConfig // pitaya.cluster.rpc.client.grpc.breaker.*, nil disables per-server breakers
+	breakerMap sync.Map       // serverID (string) -> *breaker.RatioBreaker
+
+	// third-party interceptors appended after the built-in error-unwrap/
+	// tracing/metrics ones; see WithUnaryInterceptors/WithStreamInterceptors
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+}
+
+// breakerConfig holds the pitaya.cluster.rpc.client.grpc.breaker.* settings
+// used to build a fresh breaker.RatioBreaker the first time a given server
+// is called.
+type breakerConfig struct {
+	failureRatio float64
+	minVolume    int
+	window       time.Duration
+	cooldown     time.Duration
+}
+
+// Option configures optional GRPCClient behavior at construction time.
+type Option func(*GRPCClient)
+
+// WithBackoffer makes Call (and the fire-and-forget RPCs) retry transient
+// failures according to b instead of surfacing them on the first try.
+func WithBackoffer(b *retry.Backoffer) Option {
+	return func(gs *GRPCClient) {
+		gs.backoffer = b
+	}
+}
+
+// tlsConfig holds the paths read from pitaya.cluster.rpc.client.grpc.tls.*
+// plus the credentials built from them. A fsnotify watcher rebuilds
+// grpcCreds whenever any of the underlying files change on disk and invokes
+// onReload, so AddServer/RemoveServer always dial with up to date
+// certificates *and* servers already connected before the rotation get
+// reconnected with them too (see GRPCClient.reconnectAll).
+type tlsConfig struct {
+	insecure       bool
+	caCertFile     string
+	clientCertFile string
+	clientKeyFile  string
+	serverName     string // overrides the server name used for cert verification
+	mutual         bool   // require/verify client certs on the server side
+
+	// onReload, when set, is called after every successful reload so the
+	// owning GRPCClient can redial already-connected servers with the
+	// refreshed credentials. May be nil (e.g. in tests).
+	onReload func()
+
+	mu        sync.RWMutex
+	grpcCreds credentials.TransportCredentials
+}
+
+// newTLSConfig builds the tlsConfig described by pitaya.cluster.rpc.client.grpc.tls.*.
+// Unless insecure is set, it loads credentials synchronously and fails
+// closed: a missing/invalid cert, key or CA bundle is returned as an error
+// instead of silently falling back to plaintext, since the entire point of
+// this config is to stop the cluster from ever dialing peers unencrypted.
+func newTLSConfig(cfg *config.Config, onReload func()) (*tlsConfig, error) {
+	t := &tlsConfig{
+		insecure:       cfg.GetBool("pitaya.cluster.rpc.client.grpc.tls.insecure"),
+		caCertFile:     cfg.GetString("pitaya.cluster.rpc.client.grpc.tls.cacert"),
+		clientCertFile: cfg.GetString("pitaya.cluster.rpc.client.grpc.tls.certfile"),
+		clientKeyFile:  cfg.GetString("pitaya.cluster.rpc.client.grpc.tls.keyfile"),
+		serverName:     cfg.GetString("pitaya.cluster.rpc.client.grpc.tls.servername"),
+		mutual:         cfg.GetBool("pitaya.cluster.rpc.client.grpc.tls.mutual"),
+		onReload:       onReload,
+	}
+	if t.insecure {
+		return t, nil
+	}
+	if err := t.reload(); err != nil {
+		return nil, fmt.Errorf("[grpc client] failed to load TLS credentials: %w", err)
+	}
+	t.watch()
+	return t, nil
+}
+
+// reload rebuilds grpcCreds from the configured cert/key/CA files and, on
+// success, calls onReload so already-dialed connections get redialed with
+// the new credentials instead of only servers added from now on.
+func (t *tlsConfig) reload() error {
+	caCert, err := ioutil.ReadFile(t.caCertFile)
+	if err != nil {
+		return fmt.Errorf("reading CA bundle: %w", err)
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caCert) {
+		return fmt.Errorf("failed to parse CA bundle %s", t.caCertFile)
+	}
+
+	cert, err := tls.LoadX509KeyPair(t.clientCertFile, t.clientKeyFile)
+	if err != nil {
+		return fmt.Errorf("loading client cert/key: %w", err)
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      certPool,
+		ServerName:   t.serverName,
+	}
+	if t.mutual {
+		tlsCfg.ClientCAs = certPool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	t.mu.Lock()
+	t.grpcCreds = credentials.NewTLS(tlsCfg)
+	t.mu.Unlock()
+
+	if t.onReload != nil {
+		t.onReload()
+	}
+	return nil
+}
+
+// watch reloads the TLS credentials whenever the cert, key or CA bundle
+// change on disk, so a rotated certificate doesn't require a restart.
+func (t *tlsConfig) watch() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Log.Warnf("[grpc client] could not start TLS file watcher: %v", err)
+		return
+	}
+	for _, f := range []string{t.caCertFile, t.clientCertFile, t.clientKeyFile} {
+		if f == "" {
+			continue
+		}
+		if err := watcher.Add(f); err != nil {
+			logger.Log.Warnf("[grpc client] could not watch TLS file %s: %v", f, err)
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if ev.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				if err := t.reload(); err != nil {
+					logger.Log.Errorf("[grpc client] failed to reload TLS credentials after %s changed: %v", ev.Name, err)
+					continue
+				}
+				logger.Log.Infof("[grpc client] reloaded TLS credentials after %s changed", ev.Name)
+			case <-watcher.Errors:
+				continue
+			}
+		}
+	}()
+}
+
+// dialOption returns the grpc.DialOption to use for connecting. A nil t or
+// an insecure one dials in plaintext; otherwise grpcCreds is guaranteed
+// loaded, since newTLSConfig refuses to hand back a non-insecure tlsConfig
+// until the initial load succeeds.
+func (t *tlsConfig) dialOption() grpc.DialOption {
+	if t == nil || t.insecure {
+		return grpc.WithInsecure()
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return grpc.WithTransportCredentials(t.grpcCreds)
 }
 
 // NewGRPCClient returns a new instance of GRPCClient
@@ -62,6 +243,7 @@ func NewGRPCClient(
 	metricsReporters []metrics.Reporter,
 	bindingStorage interfaces.BindingStorage, //etcd上存储用户连接的前端服务器的serverid
 	infoRetriever InfoRetriever,
+	opts ...Option,
 ) (*GRPCClient, error) {
 	gs := &GRPCClient{
 		bindingStorage:   bindingStorage,
@@ -70,16 +252,30 @@ func NewGRPCClient(
 		server:           server,
 	}
 
-	gs.configure(config)
+	if err := gs.configure(config); err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(gs)
+	}
 	return gs, nil
 }
 
 type grpcClient struct {
-	address   string
-	cli       protos.PitayaClient //grpc生成的client sub
-	conn      *grpc.ClientConn    //grpc网络连接客户端
-	connected bool
-	lock      sync.Mutex
+	address            string
+	lock               sync.Mutex
+	dialOption         grpc.DialOption // TLS or insecure, picked per-server so mixed-region clusters can migrate incrementally
+	tlsOptOut          bool            // true if this server opted out of cluster TLS via metadata; reconnectAll skips it
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+
+	poolSize            int
+	keepalive           keepalive.ClientParameters
+	healthCheckInterval time.Duration
+
+	pool      []*pooledConn
+	connected bool // true once the pool has at least been dialed once
+	rrCounter uint64
 }
 
 // Init inits grpc rpc client
@@ -87,10 +283,134 @@ func (gs *GRPCClient) Init() error {
 	return nil
 }
 
-func (gs *GRPCClient) configure(cfg *config.Config) {
+func (gs *GRPCClient) configure(cfg *config.Config) error {
 	gs.dialTimeout = cfg.GetDuration("pitaya.cluster.rpc.client.grpc.dialtimeout")
 	gs.lazy = cfg.GetBool("pitaya.cluster.rpc.client.grpc.lazyconnection")
 	gs.reqTimeout = cfg.GetDuration("pitaya.cluster.rpc.client.grpc.requesttimeout")
+
+	tlsConfig, err := newTLSConfig(cfg, gs.reconnectAll)
+	if err != nil {
+		return err
+	}
+	gs.tlsConfig = tlsConfig
+
+	gs.poolSize = cfg.GetInt("pitaya.cluster.rpc.client.grpc.pool.size")
+	if gs.poolSize <= 0 {
+		gs.poolSize = 1
+	}
+	gs.healthCheckInterval = cfg.GetDuration("pitaya.cluster.rpc.client.grpc.healthcheckinterval")
+	if gs.healthCheckInterval <= 0 {
+		gs.healthCheckInterval = 30 * time.Second
+	}
+	gs.keepalive = keepalive.ClientParameters{
+		Time:                cfg.GetDuration("pitaya.cluster.rpc.client.grpc.keepalive.time"),
+		Timeout:             cfg.GetDuration("pitaya.cluster.rpc.client.grpc.keepalive.timeout"),
+		PermitWithoutStream: cfg.GetBool("pitaya.cluster.rpc.client.grpc.keepalive.permitwithoutstream"),
+	}
+
+	if maxRetries := cfg.GetInt("pitaya.cluster.rpc.client.grpc.retry.maxretries"); maxRetries > 0 {
+		gs.backoffer = retry.NewBackoffer(
+			cfg.GetDuration("pitaya.cluster.rpc.client.grpc.retry.basedelay"),
+			cfg.GetDuration("pitaya.cluster.rpc.client.grpc.retry.maxdelay"),
+			maxRetries,
+			cfg.GetDuration("pitaya.cluster.rpc.client.grpc.retry.jitter"),
+		)
+	}
+
+	if minVolume := cfg.GetInt("pitaya.cluster.rpc.client.grpc.breaker.minvolume"); minVolume > 0 {
+		gs.breakerCfg = &breakerConfig{
+			failureRatio: getFloat64(cfg, "pitaya.cluster.rpc.client.grpc.breaker.failureratio"),
+			minVolume:    minVolume,
+			window:       cfg.GetDuration("pitaya.cluster.rpc.client.grpc.breaker.window"),
+			cooldown:     cfg.GetDuration("pitaya.cluster.rpc.client.grpc.breaker.cooldown"),
+		}
+	}
+	return nil
+}
+
+// getFloat64 reads key as a float64. *config.Config has no GetFloat64 of
+// its own, so this parses the string form the same way viper-backed
+// configs represent any value not covered by one of its typed Get*
+// methods.
+func getFloat64(cfg *config.Config, key string) float64 {
+	rate, err := strconv.ParseFloat(cfg.GetString(key), 64)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// getBreaker returns the RatioBreaker guarding calls to serverID, lazily
+// building one from gs.breakerCfg the first time it's needed, or nil when
+// breaker.* isn't configured.
+func (gs *GRPCClient) getBreaker(serverID string) *breaker.RatioBreaker {
+	if gs.breakerCfg == nil {
+		return nil
+	}
+	if v, ok := gs.breakerMap.Load(serverID); ok {
+		return v.(*breaker.RatioBreaker)
+	}
+	b := breaker.NewRatio(gs.breakerCfg.failureRatio, gs.breakerCfg.minVolume, gs.breakerCfg.window, gs.breakerCfg.cooldown)
+	actual, _ := gs.breakerMap.LoadOrStore(serverID, b)
+	return actual.(*breaker.RatioBreaker)
+}
+
+// reportBreakerState publishes brk's current state as a gauge tagged by
+// server, so operators can alert on a peer flapping between closed and open.
+func (gs *GRPCClient) reportBreakerState(serverID string, brk *breaker.RatioBreaker) {
+	if gs.metricsReporters == nil {
+		return
+	}
+	state := brk.CurrentState()
+	for _, r := range gs.metricsReporters {
+		r.ReportGauge("rpc_client_breaker_state", map[string]string{"server": serverID}, float64(state))
+	}
+}
+
+// BreakerState reports the current circuit breaker state for serverID, for
+// admin endpoints/diagnostics. Servers with no configured breaker, or that
+// have never been called, report "closed".
+func (gs *GRPCClient) BreakerState(serverID string) string {
+	v, ok := gs.breakerMap.Load(serverID)
+	if !ok {
+		return breaker.Closed.String()
+	}
+	return v.(*breaker.RatioBreaker).CurrentState().String()
+}
+
+// doWithRetry runs fn once, or repeatedly according to gs.backoffer when one
+// is configured, honoring ctxT's deadline across attempts.
+func (gs *GRPCClient) doWithRetry(ctxT context.Context, fn func(ctx context.Context) error) error {
+	if gs.backoffer == nil {
+		return fn(ctxT)
+	}
+	return gs.backoffer.Do(ctxT, func(attemptCtx context.Context, attempt int) error {
+		return fn(attemptCtx)
+	})
+}
+
+// breakerGuard runs fn through serverID's circuit breaker (if one is
+// configured): it rejects immediately with constants.ErrCircuitOpen while
+// the breaker is open, and otherwise records fn's outcome as a single
+// success/failure once fn returns. Every entry point that reaches into
+// clientMap for a given server (Call, and the fire-and-forget RPCs below)
+// goes through this so a flapping peer can't accumulate blocked goroutines
+// on any of them, not just Call.
+func (gs *GRPCClient) breakerGuard(serverID string, fn func() error) error {
+	brk := gs.getBreaker(serverID)
+	if brk != nil && !brk.Allow() {
+		return constants.ErrCircuitOpen
+	}
+	err := fn()
+	if brk != nil {
+		if err != nil {
+			brk.Failure()
+		} else {
+			brk.Success()
+		}
+		gs.reportBreakerState(serverID, brk)
+	}
+	return err
 }
 
 // Call 查找连接到server的 grpc client 然后构建protos.Request参数进行 rpc call调用返回 protos.Response
@@ -107,20 +427,9 @@ func (gs *GRPCClient) Call(
 		return nil, constants.ErrNoConnectionToServer
 	}
 
-	parent, err := tracing.ExtractSpan(ctx)
-	if err != nil {
-		logger.Log.Warnf("[grpc client] failed to retrieve parent span: %s", err.Error())
-	}
-	tags := opentracing.Tags{
-		"span.kind":       "client",
-		"local.id":        gs.server.ID,
-		"peer.serverType": server.Type,
-		"peer.id":         server.ID,
-	}
-	ctx = tracing.StartSpan(ctx, "RPC Call", tags, parent)
-	defer tracing.FinishSpan(ctx, err)
-
 	//构建rpc调用的请求protos.Request
+	// tracing and timing are handled by the unary interceptor chain set up
+	// in AddServer (see interceptorsFor), not inline here anymore
 	req, err := buildRequest(ctx, rpcType, route, session, msg, gs.server)
 	if err != nil {
 		return nil, err
@@ -128,19 +437,43 @@ func (gs *GRPCClient) Call(
 
 	ctxT, done := context.WithTimeout(ctx, gs.reqTimeout)
 	defer done()
-
-	if gs.metricsReporters != nil {
-		startTime := time.Now()
-		ctxT = pcontext.AddToPropagateCtx(ctxT, constants.StartTimeKey, startTime.UnixNano())
-		ctxT = pcontext.AddToPropagateCtx(ctxT, constants.RouteKey, route.String())
-		defer metrics.ReportTimingFromCtx(ctxT, gs.metricsReporters, "rpc", err)
-	}
+	ctxT = withRPCRoute(ctxT, route.String())
 
 	//grpc call远程过程调用 且返回protos.Response
-	res, err := c.(*grpcClient).call(ctxT, &req)
+	// the breaker only judges transport-level failures (timeouts,
+	// Unavailable, ...), not application errors the remote handler returned
+	// in res.Error, and the whole retry sequence below counts as the single
+	// outcome breakerGuard records, so a half-open probe isn't burned once
+	// per retry attempt
+	var res *protos.Response
+	err = gs.breakerGuard(server.ID, func() error {
+		if gs.backoffer != nil {
+			return gs.backoffer.Do(ctxT, func(attemptCtx context.Context, attempt int) error {
+				if attempt > 0 {
+					attemptCtx = pcontext.AddToPropagateCtx(attemptCtx, constants.RetryAttemptKey, attempt)
+				}
+				var callErr error
+				res, callErr = c.(*grpcClient).call(attemptCtx, &req)
+				return callErr
+			})
+		}
+		var callErr error
+		res, callErr = c.(*grpcClient).call(ctxT, &req)
+		return callErr
+	})
 	if err != nil {
 		return nil, err
 	}
+	// res.Error is the in-band channel a handler uses to report an
+	// application error inside an otherwise-successful RPC (the call
+	// itself reached and returned from the remote handler); it's handled
+	// here, not by the interceptor chain, since by this point err above is
+	// already nil. errorUnwrapUnaryClientInterceptor covers the separate,
+	// transport-level case of a call that never reached the handler (e.g.
+	// middleware rejecting it) by recovering a Pitaya code from the gRPC
+	// status instead. The two paths are intentionally kept side by side,
+	// not merged, because they report failures that happen at different
+	// layers of the call.
 	if res.Error != nil {
 		if res.Error.Code == "" {
 			res.Error.Code = pitErrors.ErrUnknownCode
@@ -176,8 +509,11 @@ func (gs *GRPCClient) BroadcastSessionBind(uid string) error {
 			}
 			ctxT, done := context.WithTimeout(context.Background(), gs.reqTimeout)
 			defer done()
-			err := c.(*grpcClient).sessionBindRemote(ctxT, msg)
-			return err
+			return gs.breakerGuard(fid, func() error {
+				return gs.doWithRetry(ctxT, func(ctx context.Context) error {
+					return c.(*grpcClient).sessionBindRemote(ctx, msg)
+				})
+			})
 		}
 	}
 	return nil
@@ -201,8 +537,11 @@ func (gs *GRPCClient) SendKick(userID string, serverType string, kick *protos.Ki
 	if c, ok := gs.clientMap.Load(svID); ok {
 		ctxT, done := context.WithTimeout(context.Background(), gs.reqTimeout)
 		defer done()
-		err := c.(*grpcClient).sendKick(ctxT, kick)
-		return err
+		return gs.breakerGuard(svID, func() error {
+			return gs.doWithRetry(ctxT, func(ctx context.Context) error {
+				return c.(*grpcClient).sendKick(ctx, kick)
+			})
+		})
 	}
 	return constants.ErrNoConnectionToServer
 }
@@ -226,8 +565,11 @@ func (gs *GRPCClient) SendPush(userID string, frontendSv *Server, push *protos.P
 	if c, ok := gs.clientMap.Load(svID); ok {
 		ctxT, done := context.WithTimeout(context.Background(), gs.reqTimeout)
 		defer done()
-		err := c.(*grpcClient).pushToUser(ctxT, push)
-		return err
+		return gs.breakerGuard(svID, func() error {
+			return gs.doWithRetry(ctxT, func(ctx context.Context) error {
+				return c.(*grpcClient).pushToUser(ctx, push)
+			})
+		})
 	}
 	return constants.ErrNoConnectionToServer
 }
@@ -251,7 +593,16 @@ func (gs *GRPCClient) AddServer(sv *Server) {
 
 	//构建一个新的grpcClient进行连接
 	address := fmt.Sprintf("%s:%s", host, port)
-	client := &grpcClient{address: address}
+	client := &grpcClient{
+		address:             address,
+		dialOption:          gs.dialOptionFor(sv),
+		tlsOptOut:           sv.Metadata[constants.GRPCTLSKey] == "false",
+		unaryInterceptors:   gs.interceptorsFor(sv),
+		streamInterceptors:  gs.streamInterceptors,
+		poolSize:            gs.poolSize,
+		keepalive:           gs.keepalive,
+		healthCheckInterval: gs.healthCheckInterval,
+	}
 	if !gs.lazy {
 		if err := client.connect(); err != nil {
 			logger.Log.Errorf("[grpc client] unable to connect to server %s at %s: %v", sv.ID, address, err)
@@ -312,28 +663,61 @@ func (gs *GRPCClient) getServerHost(sv *Server) (host, portKey string) {
 	return externalHost, constants.GRPCExternalPortKey
 }
 
+// dialOptionFor decides whether to dial sv over TLS or plaintext: a server
+// can opt out of the cluster-wide TLS config via its own metadata, which
+// lets a mixed-region cluster migrate to TLS incrementally instead of
+// flipping every server over at once.
+func (gs *GRPCClient) dialOptionFor(sv *Server) grpc.DialOption {
+	if tlsMeta, ok := sv.Metadata[constants.GRPCTLSKey]; ok && tlsMeta == "false" {
+		return grpc.WithInsecure()
+	}
+	return gs.tlsConfig.dialOption()
+}
+
+// reconnectAll is tlsConfig's onReload callback: it redials every currently
+// known server that uses the cluster TLS config with the freshly reloaded
+// credentials, so a rotated certificate takes effect on connections dialed
+// before the rotation too, not just ones AddServer dials afterwards.
+// Servers that opted out of cluster TLS via metadata are left untouched.
+func (gs *GRPCClient) reconnectAll() {
+	gs.clientMap.Range(func(_, value interface{}) bool {
+		client := value.(*grpcClient)
+		if !client.tlsOptOut {
+			client.updateDialOption(gs.tlsConfig.dialOption())
+		}
+		return true
+	})
+}
+
 //--------------------grpcClient--------------------------------
 
-//connect连接指定server上的grpc server
+//connect dials gc.poolSize (default 1) connections to the server and starts
+//health-monitoring them; see pooledConn in pool.go
 func (gc *grpcClient) connect() error {
 	gc.lock.Lock()
 	defer gc.lock.Unlock()
 	if gc.connected {
 		return nil
 	}
-	//连接grpc server
-	conn, err := grpc.Dial(
-		gc.address,
-		grpc.WithInsecure(),
-	)
-	if err != nil {
-		return err
+
+	size := gc.poolSize
+	if size <= 0 {
+		size = 1
 	}
 
-	//生成grpc客户端 传入gprc conn用来做 grpc方法调用
-	c := protos.NewPitayaClient(conn)
-	gc.cli = c
-	gc.conn = conn
+	pool := make([]*pooledConn, 0, size)
+	for i := 0; i < size; i++ {
+		pc, err := newPooledConn(gc)
+		if err != nil {
+			for _, p := range pool {
+				p.close()
+			}
+			return err
+		}
+		pool = append(pool, pc)
+	}
+
+	gc.pool = pool
 	gc.connected = true
 	return nil
 }
@@ -342,48 +726,108 @@ func (gc *grpcClient) connect() error {
 func (gc *grpcClient) disconnect() {
 	gc.lock.Lock()
 	if gc.connected {
-		gc.conn.Close()
+		for _, pc := range gc.pool {
+			pc.close()
+		}
+		gc.pool = nil
 		gc.connected = false
 	}
 	gc.lock.Unlock()
 }
 
-// pushToUser  call  sessionBindRemote sendKick 使用protos.PitayaClient完成调用
-func (gc *grpcClient) pushToUser(ctx context.Context, push *protos.Push) error {
-	if !gc.connected {
-		if err := gc.connect(); err != nil {
-			return err
+// updateDialOption swaps the DialOption gc dials with (e.g. after a TLS
+// cert rotation) and, if gc was already connected, redials its pool so the
+// change takes effect immediately instead of only on the next fresh dial.
+func (gc *grpcClient) updateDialOption(opt grpc.DialOption) {
+	gc.lock.Lock()
+	gc.dialOption = opt
+	gc.lock.Unlock()
+
+	if err := gc.reconnect(); err != nil {
+		logger.Log.Errorf("[grpc client] failed to reconnect %s with updated dial option: %v", gc.address, err)
+	}
+}
+
+// reconnect tears down and redials gc's pool using its current dialOption.
+// It's a no-op for a lazily-connected gc that hasn't dialed yet, since the
+// next healthyConn call will connect with whatever dialOption is current.
+func (gc *grpcClient) reconnect() error {
+	gc.lock.Lock()
+	wasConnected := gc.connected
+	if wasConnected {
+		for _, pc := range gc.pool {
+			pc.close()
 		}
+		gc.pool = nil
+		gc.connected = false
 	}
-	_, err := gc.cli.PushToUser(ctx, push)
-	return err
+	gc.lock.Unlock()
+
+	if !wasConnected {
+		return nil
+	}
+	return gc.connect()
 }
 
-func (gc *grpcClient) call(ctx context.Context, req *protos.Request) (*protos.Response, error) {
+// healthyConn round-robins over gc.pool, returning the next conn the health
+// checker still considers up. If none are currently healthy it falls back
+// to round-robining over all of them, rather than failing outright, since a
+// stale health check is better than refusing to even try.
+func (gc *grpcClient) healthyConn() (*pooledConn, error) {
 	if !gc.connected {
 		if err := gc.connect(); err != nil {
 			return nil, err
 		}
 	}
-	return gc.cli.Call(ctx, req)
+	gc.lock.Lock()
+	pool := gc.pool
+	gc.lock.Unlock()
+	if len(pool) == 0 {
+		return nil, constants.ErrNoConnectionToServer
+	}
+
+	n := atomic.AddUint64(&gc.rrCounter, 1)
+	for i := 0; i < len(pool); i++ {
+		pc := pool[(int(n)+i)%len(pool)]
+		if pc.isHealthy() {
+			return pc, nil
+		}
+	}
+	return pool[int(n)%len(pool)], nil
+}
+
+// pushToUser  call  sessionBindRemote sendKick 使用protos.PitayaClient完成调用
+func (gc *grpcClient) pushToUser(ctx context.Context, push *protos.Push) error {
+	pc, err := gc.healthyConn()
+	if err != nil {
+		return err
+	}
+	_, err = pc.cli.PushToUser(ctx, push)
+	return err
+}
+
+func (gc *grpcClient) call(ctx context.Context, req *protos.Request) (*protos.Response, error) {
+	pc, err := gc.healthyConn()
+	if err != nil {
+		return nil, err
+	}
+	return pc.cli.Call(ctx, req)
 }
 
 func (gc *grpcClient) sessionBindRemote(ctx context.Context, req *protos.BindMsg) error {
-	if !gc.connected {
-		if err := gc.connect(); err != nil {
-			return err
-		}
+	pc, err := gc.healthyConn()
+	if err != nil {
+		return err
 	}
-	_, err := gc.cli.SessionBindRemote(ctx, req)
+	_, err = pc.cli.SessionBindRemote(ctx, req)
 	return err
 }
 
 func (gc *grpcClient) sendKick(ctx context.Context, req *protos.KickMsg) error {
-	if !gc.connected {
-		if err := gc.connect(); err != nil {
-			return err
-		}
+	pc, err := gc.healthyConn()
+	if err != nil {
+		return err
 	}
-	_, err := gc.cli.KickUser(ctx, req)
+	_, err = pc.cli.KickUser(ctx, req)
 	return err
 }
@@ -0,0 +1,241 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pcontext "github.com/topfreegames/pitaya/context"
+	"github.com/topfreegames/pitaya/constants"
+	pitErrors "github.com/topfreegames/pitaya/errors"
+	"github.com/topfreegames/pitaya/logger"
+	"github.com/topfreegames/pitaya/metrics"
+	"github.com/topfreegames/pitaya/tracing"
+)
+
+// errorDetailsDomain tags the errdetails.ErrorInfo this package attaches to
+// a gRPC status, so errorUnwrapUnaryClientInterceptor only unwraps details
+// this cluster put there instead of ones from some other service in the
+// call chain.
+const errorDetailsDomain = "pitaya"
+
+// errorUnwrapUnaryClientInterceptor converts a gRPC status error that
+// carries a Pitaya errdetails.ErrorInfo (as attached by
+// errorDetailsUnaryServerInterceptor on the peer) back into a
+// *pitErrors.Error. This is a distinct, transport-level error path from
+// res.Error in GRPCClient.Call: res.Error is how a *handler* reports an
+// application error inside an otherwise-successful RPC response, while this
+// interceptor recovers a Pitaya code from an RPC that failed at the gRPC
+// level (e.g. server-side middleware rejecting a call before it ever
+// reaches the handler). It does not replace the res.Error handling in Call.
+func errorUnwrapUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil {
+			return nil
+		}
+		st, ok := status.FromError(err)
+		if !ok {
+			return err
+		}
+		for _, d := range st.Details() {
+			if info, ok := d.(*errdetails.ErrorInfo); ok && info.Domain == errorDetailsDomain {
+				return &pitErrors.Error{Code: info.Reason, Message: st.Message(), Metadata: info.Metadata}
+			}
+		}
+		// a status with no Pitaya details is returned as is, keeping its
+		// gRPC code for the retry.Backoffer to judge
+		return err
+	}
+}
+
+// tracingUnaryClientInterceptor starts a "RPC Call" span around every
+// outgoing RPC, tagged with the peer server, moving what used to be
+// hand-rolled in GRPCClient.Call into a reusable interceptor.
+func tracingUnaryClientInterceptor(gs *GRPCClient, server *Server) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		parent, err := tracing.ExtractSpan(ctx)
+		if err != nil {
+			logger.Log.Warnf("[grpc client] failed to retrieve parent span: %s", err.Error())
+		}
+		tags := opentracing.Tags{
+			"span.kind":       "client",
+			"local.id":        gs.server.ID,
+			"peer.serverType": server.Type,
+			"peer.id":         server.ID,
+			"rpc.method":      method,
+		}
+		ctx = tracing.StartSpan(ctx, "RPC Call", tags, parent)
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		tracing.FinishSpan(ctx, err)
+		return err
+	}
+}
+
+// rpcRouteKey is a local-only context key (never sent to the peer, unlike
+// the Pitaya propagate context) that Call attaches the business route to
+// before invoking the interceptor chain, so metricsUnaryClientInterceptor
+// can label "rpc" timing metrics by the actual route (e.g. "room.join")
+// instead of the gRPC method name, which is always "/protos.Pitaya/Call"
+// since every route is multiplexed over that single RPC.
+type rpcRouteKey struct{}
+
+// withRPCRoute attaches rt to ctx for metricsUnaryClientInterceptor to pick
+// up; see rpcRouteKey.
+func withRPCRoute(ctx context.Context, rt string) context.Context {
+	return context.WithValue(ctx, rpcRouteKey{}, rt)
+}
+
+// rpcRouteFromContext returns the route attached by withRPCRoute, if any.
+// The fire-and-forget RPCs (PushToUser, SessionBindRemote, KickUser) have no
+// business route to attach, so callers fall back to the gRPC method name.
+func rpcRouteFromContext(ctx context.Context) (string, bool) {
+	rt, ok := ctx.Value(rpcRouteKey{}).(string)
+	return rt, ok
+}
+
+// metricsUnaryClientInterceptor reports RPC timing through gs.metricsReporters,
+// the same way GRPCClient.Call used to do it inline.
+func metricsUnaryClientInterceptor(gs *GRPCClient) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if gs.metricsReporters == nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		route := method
+		if rt, ok := rpcRouteFromContext(ctx); ok {
+			route = rt
+		}
+		startTime := time.Now()
+		ctx = pcontext.AddToPropagateCtx(ctx, constants.StartTimeKey, startTime.UnixNano())
+		ctx = pcontext.AddToPropagateCtx(ctx, constants.RouteKey, route)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		metrics.ReportTimingFromCtx(ctx, gs.metricsReporters, "rpc", err)
+		return err
+	}
+}
+
+// binMetadataSuffix marks a gRPC metadata key as carrying binary data, which
+// is how the gRPC wire format tells apart ASCII header values from ones that
+// need base64 transport; see google.golang.org/grpc/metadata.
+const binMetadataSuffix = "-bin"
+
+// propagateCtxUnaryClientInterceptor carries every key/value pair in the
+// Pitaya propagate context (request-id, trace baggage, tenant, locale, ...)
+// over as gRPC metadata, so it reaches the peer on every cluster RPC
+// (PushToUser, KickUser, SessionBindRemote) and not just Call, which used to
+// be the only one that serialized it into the protos.Request body.
+func propagateCtxUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if m := pcontext.GetPropagateCtx(ctx); len(m) > 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, encodePropagateCtx(m)...)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// encodePropagateCtx flattens a propagate context map into the flat
+// key/value pairs metadata.AppendToOutgoingContext expects, sending []byte
+// values through a "<key>-bin" key per gRPC's binary header convention and
+// everything else through the plain key as its default string formatting.
+//
+// Keys are lowercased before being sent: grpc-go's metadata layer lowercases
+// every outgoing header key on the wire regardless of what we pass in (see
+// metadata.FromOutgoingContext), so a mixed-case key like "requestId" would
+// otherwise come back as "requestid" on the decode side. Lowercasing here
+// instead of relying on that makes the behavior explicit and matches what
+// decodePropagateCtx (and any peer talking to us) actually observes.
+func encodePropagateCtx(m map[string]interface{}) []string {
+	kv := make([]string, 0, len(m)*2)
+	for k, v := range m {
+		key := strings.ToLower(k)
+		if b, ok := v.([]byte); ok {
+			kv = append(kv, key+binMetadataSuffix, string(b))
+			continue
+		}
+		kv = append(kv, key, fmt.Sprintf("%v", v))
+	}
+	return kv
+}
+
+// WithUnaryInterceptors appends interceptors (auth, rate-limiting, panic
+// recovery, ...) to the chain every grpcClient dials with, after the
+// built-in error-unwrap/tracing/metrics ones.
+func (gs *GRPCClient) WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) *GRPCClient {
+	gs.unaryInterceptors = append(gs.unaryInterceptors, interceptors...)
+	return gs
+}
+
+// WithStreamInterceptors appends stream interceptors to the chain every
+// grpcClient dials with.
+func (gs *GRPCClient) WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) *GRPCClient {
+	gs.streamInterceptors = append(gs.streamInterceptors, interceptors...)
+	return gs
+}
+
+// interceptorsFor builds the full unary interceptor chain for an RPC to
+// server: the built-ins first (so error unwrapping/tracing/metrics wrap
+// every retry), then any third-party ones appended via WithUnaryInterceptors.
+func (gs *GRPCClient) interceptorsFor(server *Server) []grpc.UnaryClientInterceptor {
+	chain := []grpc.UnaryClientInterceptor{
+		tracingUnaryClientInterceptor(gs, server),
+		metricsUnaryClientInterceptor(gs),
+		propagateCtxUnaryClientInterceptor(),
+		errorUnwrapUnaryClientInterceptor(),
+	}
+	return append(chain, gs.unaryInterceptors...)
+}
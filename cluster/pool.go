@@ -0,0 +1,185 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cluster
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/topfreegames/pitaya/logger"
+	"github.com/topfreegames/pitaya/protos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// pooledConn is a single dialed connection inside a grpcClient's pool: it
+// watches its own connectivity state to notice drops as soon as they happen
+// (rather than waiting for the next call to fail), reconnects with backoff,
+// and is periodically probed via the standard gRPC health protocol so a conn
+// that's technically Ready but serving errors still gets taken out of
+// rotation.
+type pooledConn struct {
+	gc   *grpcClient
+	conn *grpc.ClientConn
+	cli  protos.PitayaClient
+	hc   grpc_health_v1.HealthClient
+
+	healthy int32 // accessed atomically; 1 == healthy, 0 == not
+
+	stopped int32 // accessed atomically; set by close() to stop the goroutines
+	done    chan struct{}
+}
+
+// newPooledConn dials gc.address once and starts the watch/health-check
+// goroutines for the resulting connection.
+func newPooledConn(gc *grpcClient) (*pooledConn, error) {
+	conn, err := dialPooledConn(gc)
+	if err != nil {
+		return nil, err
+	}
+
+	pc := &pooledConn{
+		gc:   gc,
+		conn: conn,
+		cli:  protos.NewPitayaClient(conn),
+		hc:   grpc_health_v1.NewHealthClient(conn),
+		done: make(chan struct{}),
+	}
+	atomic.StoreInt32(&pc.healthy, 1)
+
+	go pc.watchState()
+	go pc.watchHealth()
+	return pc, nil
+}
+
+func dialPooledConn(gc *grpcClient) (*grpc.ClientConn, error) {
+	dialOption := gc.dialOption
+	if dialOption == nil {
+		dialOption = grpc.WithInsecure()
+	}
+	return grpc.Dial(
+		gc.address,
+		dialOption,
+		grpc.WithChainUnaryInterceptor(gc.unaryInterceptors...),
+		grpc.WithChainStreamInterceptor(gc.streamInterceptors...),
+		grpc.WithKeepaliveParams(gc.keepalive),
+	)
+}
+
+func (pc *pooledConn) isHealthy() bool {
+	return atomic.LoadInt32(&pc.healthy) == 1
+}
+
+func (pc *pooledConn) isStopped() bool {
+	return atomic.LoadInt32(&pc.stopped) == 1
+}
+
+// watchState marks the conn unhealthy as soon as it enters TransientFailure
+// or Shutdown, and on TransientFailure kicks off reconnectWithBackoff instead
+// of waiting for a caller to notice. Other state changes leave it healthy.
+func (pc *pooledConn) watchState() {
+	ctx := context.Background()
+	state := pc.conn.GetState()
+	for !pc.isStopped() {
+		if !pc.conn.WaitForStateChange(ctx, state) {
+			return
+		}
+		state = pc.conn.GetState()
+		switch state {
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			atomic.StoreInt32(&pc.healthy, 0)
+			if state == connectivity.TransientFailure {
+				go pc.reconnectWithBackoff()
+			}
+			return
+		}
+	}
+}
+
+// reconnectWithBackoff waits for pc.conn to come back without redialing it
+// itself (grpc.ClientConn retries internally): it probes the health endpoint
+// with a bounded exponential backoff (500ms doubling up to 30s, plus up to
+// 500ms of jitter) and flips pc.healthy back once the server responds again.
+func (pc *pooledConn) reconnectWithBackoff() {
+	base := 500 * time.Millisecond
+	max := 30 * time.Second
+	delay := base
+	for attempt := 0; !pc.isStopped(); attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := pc.hc.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		cancel()
+		if err == nil {
+			atomic.StoreInt32(&pc.healthy, 1)
+			go pc.watchState()
+			return
+		}
+
+		logger.Log.Warnf("[grpc client] %s still unreachable, retrying in %s: %v", pc.gc.address, delay, err)
+		select {
+		case <-time.After(delay + time.Duration(rand.Int63n(int64(base)))):
+		case <-pc.done:
+			return
+		}
+		delay *= 2
+		if delay > max {
+			delay = max
+		}
+	}
+}
+
+// watchHealth periodically calls the standard gRPC health check and evicts
+// the conn from rotation (without tearing it down) whenever it reports
+// anything but SERVING, catching cases where the TCP connection is fine but
+// the remote pitaya instance itself is unhealthy.
+func (pc *pooledConn) watchHealth() {
+	interval := pc.gc.healthCheckInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			resp, err := pc.hc.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+			cancel()
+			if err != nil || resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+				atomic.StoreInt32(&pc.healthy, 0)
+				continue
+			}
+			atomic.StoreInt32(&pc.healthy, 1)
+		case <-pc.done:
+			return
+		}
+	}
+}
+
+func (pc *pooledConn) close() {
+	if !atomic.CompareAndSwapInt32(&pc.stopped, 0, 1) {
+		return
+	}
+	close(pc.done)
+	pc.conn.Close()
+}
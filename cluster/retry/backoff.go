@@ -0,0 +1,128 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package retry implements a configurable retry/backoff policy for cluster
+// RPCs, inspired by the TiKV client's WithBackoffer option: callers wrap a
+// single attempt in Backoffer.Do and get exponential-backoff-with-jitter
+// retries for whichever grpc codes the policy considers transient.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Backoffer configures how GRPCClient retries a failed RPC.
+type Backoffer struct {
+	Base       time.Duration        // delay before the first retry
+	Max        time.Duration        // delay never grows past this
+	MaxRetries int                  // attempts after the first one, i.e. total tries == MaxRetries+1
+	Jitter     time.Duration        // a random amount up to this is added to every delay
+	Retryable  func(codes.Code) bool // nil means DefaultRetryable
+}
+
+// NewBackoffer returns a Backoffer with the given base/max delay and max
+// number of retries, using DefaultRetryable.
+func NewBackoffer(base, max time.Duration, maxRetries int, jitter time.Duration) *Backoffer {
+	return &Backoffer{Base: base, Max: max, MaxRetries: maxRetries, Jitter: jitter}
+}
+
+// DefaultRetryable retries Unavailable, DeadlineExceeded and
+// ResourceExhausted, and never retries InvalidArgument, Unauthenticated, or
+// any other code (including application-level errors, which never reach
+// this predicate since they're returned as a *protos.Response, not a gRPC
+// status error).
+func DefaultRetryable(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Backoffer) retryable(code codes.Code) bool {
+	if b.Retryable != nil {
+		return b.Retryable(code)
+	}
+	return DefaultRetryable(code)
+}
+
+// delay returns how long to sleep before retry attempt n (1-indexed),
+// following min(base*2^(n-1), max) + rand(jitter).
+func (b *Backoffer) delay(n int) time.Duration {
+	d := b.Base << uint(n-1)
+	if d > b.Max || d <= 0 {
+		d = b.Max
+	}
+	if b.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(b.Jitter)))
+	}
+	return d
+}
+
+// Do calls fn, and if it fails with a retryable gRPC status, retries it up
+// to MaxRetries times with exponential backoff. fn receives a ctx derived
+// from the parent with the same deadline remaining on every attempt; it is
+// up to the caller to honor ctx.Done() inside fn. attempt is 0 on the first
+// (non-retried) call and increments on every subsequent retry, so callers
+// can tag a metric like retry_attempt.
+func (b *Backoffer) Do(ctx context.Context, fn func(ctx context.Context, attempt int) error) error {
+	var lastErr error
+	for attempt := 0; attempt <= b.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(b.delay(attempt)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		attemptCtx := ctx
+		cancel := func() {}
+		if deadline, ok := ctx.Deadline(); ok {
+			attemptCtx, cancel = context.WithDeadline(ctx, deadline)
+		}
+
+		err := fn(attemptCtx, attempt)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		st, ok := status.FromError(err)
+		if !ok || !b.retryable(st.Code()) {
+			return err
+		}
+		// DeadlineExceeded is only worth retrying if the parent context
+		// still has budget left for another attempt
+		if st.Code() == codes.DeadlineExceeded {
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= 0 {
+				return err
+			}
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,126 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDelayExponentialWithCap(t *testing.T) {
+	b := &Backoffer{Base: 100 * time.Millisecond, Max: time.Second}
+
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second}, // would be 1.6s uncapped, clamped to Max
+		{10, time.Second},
+	}
+	for _, c := range cases {
+		if got := b.delay(c.attempt); got != c.want {
+			t.Errorf("delay(%d) = %s, want %s", c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestDelayJitterStaysWithinBounds(t *testing.T) {
+	b := &Backoffer{Base: 100 * time.Millisecond, Max: time.Second, Jitter: 50 * time.Millisecond}
+	for i := 0; i < 20; i++ {
+		d := b.delay(2)
+		if d < 200*time.Millisecond || d >= 250*time.Millisecond {
+			t.Fatalf("delay(2) = %s, want in [200ms, 250ms)", d)
+		}
+	}
+}
+
+func TestDoRetriesRetryableCodesUpToMaxRetries(t *testing.T) {
+	b := &Backoffer{Base: time.Millisecond, Max: time.Millisecond, MaxRetries: 2}
+	attempts := 0
+	err := b.Do(context.Background(), func(ctx context.Context, attempt int) error {
+		attempts++
+		return status.Error(codes.Unavailable, "down")
+	})
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3 (1 initial + 2 retries)", attempts)
+	}
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("err code = %v, want Unavailable", status.Code(err))
+	}
+}
+
+func TestDoStopsOnNonRetryableCode(t *testing.T) {
+	b := &Backoffer{Base: time.Millisecond, Max: time.Millisecond, MaxRetries: 3}
+	attempts := 0
+	err := b.Do(context.Background(), func(ctx context.Context, attempt int) error {
+		attempts++
+		return status.Error(codes.InvalidArgument, "bad")
+	})
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("err code = %v, want InvalidArgument", status.Code(err))
+	}
+}
+
+func TestDoStopsOnNonStatusError(t *testing.T) {
+	b := &Backoffer{Base: time.Millisecond, Max: time.Millisecond, MaxRetries: 3}
+	attempts := 0
+	plain := errors.New("boom")
+	err := b.Do(context.Background(), func(ctx context.Context, attempt int) error {
+		attempts++
+		return plain
+	})
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+	if err != plain {
+		t.Errorf("err = %v, want %v", err, plain)
+	}
+}
+
+func TestDoSucceedsWithoutExhaustingRetries(t *testing.T) {
+	b := &Backoffer{Base: time.Millisecond, Max: time.Millisecond, MaxRetries: 5}
+	attempts := 0
+	err := b.Do(context.Background(), func(ctx context.Context, attempt int) error {
+		attempts++
+		if attempts < 3 {
+			return status.Error(codes.Unavailable, "down")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err = %v, want nil", err)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cluster
+
+import (
+	"context"
+	"strings"
+
+	pcontext "github.com/topfreegames/pitaya/context"
+	pitErrors "github.com/topfreegames/pitaya/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// PropagateCtxUnaryServerInterceptor reverses
+// propagateCtxUnaryClientInterceptor: it reads back whatever propagate
+// context the peer sent as gRPC metadata and repopulates it on ctx before
+// handing off to handler, so a remote handler sees the same request-id,
+// trace baggage, tenant and locale that Call/PushToUser/KickUser/
+// SessionBindRemote started with on the caller's side.
+//
+// Exported standalone so it composes with whatever else a server chains,
+// but most callers want it bundled with the other built-in server
+// interceptors via ServerUnaryInterceptors instead of listing it alone.
+func PropagateCtxUnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = decodePropagateCtx(ctx, md)
+	}
+	return handler(ctx, req)
+}
+
+// errorDetailsUnaryServerInterceptor converts a *pitErrors.Error returned by
+// handler into a gRPC status carrying an errdetails.ErrorInfo, so the
+// peer's errorUnwrapUnaryClientInterceptor can recover the Pitaya code
+// instead of only seeing a bare gRPC status. A plain (non-*pitErrors.Error) error is passed
+// through unchanged, since it's already either a valid gRPC status error or
+// will be turned into codes.Unknown by grpc-go itself.
+func errorDetailsUnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	pitErr, ok := err.(*pitErrors.Error)
+	if !ok {
+		return resp, err
+	}
+
+	st, stErr := status.New(grpcCodeFor(pitErr.Code), pitErr.Message).WithDetails(&errdetails.ErrorInfo{
+		Reason:   pitErr.Code,
+		Domain:   errorDetailsDomain,
+		Metadata: pitErr.Metadata,
+	})
+	if stErr != nil {
+		return resp, err
+	}
+	return resp, st.Err()
+}
+
+// grpcCodeFor maps a Pitaya error code to the closest standard gRPC status
+// code, falling back to codes.Unknown for anything it doesn't recognize
+// (including application-specific codes a component defines itself).
+func grpcCodeFor(code string) codes.Code {
+	switch code {
+	case pitErrors.ErrNotFoundCode:
+		return codes.NotFound
+	case pitErrors.ErrBadRequestCode:
+		return codes.InvalidArgument
+	case pitErrors.ErrRateLimitExceededCode:
+		return codes.ResourceExhausted
+	case pitErrors.ErrServiceUnavailableCode:
+		return codes.Unavailable
+	case pitErrors.ErrInternalCode:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
+
+// ServerUnaryInterceptors returns the built-in unary server interceptors
+// this package expects to run on every cluster RPC: propagate-context
+// decoding and Pitaya error-detail encoding. GRPCServer (grpc_rpc_server.go)
+// isn't part of this snapshot, so wiring this in is left to whoever owns
+// it; it just needs to be passed to grpc.ChainUnaryInterceptor alongside
+// whatever else the server already registers:
+//
+//	grpc.ChainUnaryInterceptor(cluster.ServerUnaryInterceptors()...)
+func ServerUnaryInterceptors() []grpc.UnaryServerInterceptor {
+	return []grpc.UnaryServerInterceptor{
+		PropagateCtxUnaryServerInterceptor,
+		errorDetailsUnaryServerInterceptor,
+	}
+}
+
+// decodePropagateCtx rebuilds the propagate context map from incoming
+// metadata, undoing encodePropagateCtx: a "<key>-bin" entry becomes a []byte
+// value, everything else is carried over as a plain string. md's keys are
+// already lowercase, both because encodePropagateCtx lowercases them before
+// sending and because grpc-go's metadata layer lowercases every incoming
+// header key regardless; callers reading a propagated value back out of ctx
+// must do so by its lowercase key.
+func decodePropagateCtx(ctx context.Context, md metadata.MD) context.Context {
+	for k, values := range md {
+		if len(values) == 0 {
+			continue
+		}
+		if strings.HasSuffix(k, binMetadataSuffix) {
+			key := strings.TrimSuffix(k, binMetadataSuffix)
+			ctx = pcontext.AddToPropagateCtx(ctx, key, []byte(values[0]))
+			continue
+		}
+		ctx = pcontext.AddToPropagateCtx(ctx, k, values[0])
+	}
+	return ctx
+}
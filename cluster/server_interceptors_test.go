@@ -0,0 +1,67 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	pcontext "github.com/topfreegames/pitaya/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// TestPropagateCtxRoundTrip exercises encodePropagateCtx and
+// decodePropagateCtx through the real grpc-go wire-format functions
+// (metadata.AppendToOutgoingContext/FromOutgoingContext), standing in for
+// an actual client->server RPC (there's no GRPCServer in this snapshot to
+// dial against): it proves whatever propagateCtxUnaryClientInterceptor
+// sends over the wire is exactly what PropagateCtxUnaryServerInterceptor's
+// decode half reconstructs on the other end, for both plain string and
+// binary values. Going through the real metadata functions also exercises
+// grpc-go's own lowercasing of every outgoing header key, which a test that
+// hand-builds metadata.MD (as this one used to) doesn't see.
+func TestPropagateCtxRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	ctx = pcontext.AddToPropagateCtx(ctx, "requestId", "abc-123")
+	ctx = pcontext.AddToPropagateCtx(ctx, "traceBaggage", []byte{0x01, 0x02, 0x03})
+
+	sent := pcontext.GetPropagateCtx(ctx)
+	kv := encodePropagateCtx(sent)
+
+	outCtx := metadata.AppendToOutgoingContext(context.Background(), kv...)
+	md, ok := metadata.FromOutgoingContext(outCtx)
+	if !ok {
+		t.Fatal("expected outgoing metadata to be present")
+	}
+
+	decoded := decodePropagateCtx(context.Background(), md)
+	got := pcontext.GetPropagateCtx(decoded)
+
+	// encodePropagateCtx lowercases keys itself (and grpc-go would have
+	// lowercased them anyway), so the decoded map is keyed in lowercase.
+	if got["requestid"] != "abc-123" {
+		t.Errorf("requestid = %v, want abc-123", got["requestid"])
+	}
+	b, ok := got["tracebaggage"].([]byte)
+	if !ok || string(b) != string([]byte{0x01, 0x02, 0x03}) {
+		t.Errorf("tracebaggage = %v, want []byte{1,2,3}", got["tracebaggage"])
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright (c) nano Author and TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package component
+
+import (
+	"context"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfBytes   = reflect.TypeOf(([]byte)(nil))
+	typeOfPusher  = reflect.TypeOf((*func(proto.Message) error)(nil)).Elem()
+)
+
+func isExported(name string) bool {
+	w, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(w)
+}
+
+// isHandlerArg reports whether t is a type suitable as a handler's typed
+// argument: []byte (passed through raw) or a pointer type unmarshaled by
+// the serializer.
+func isHandlerArg(t reflect.Type) bool {
+	return t == typeOfBytes || t.Kind() == reflect.Ptr
+}
+
+// validateHandlerMethod checks that mt (an unbound method, so In(0) is the
+// receiver) matches the shape documented on Service.ExtractHandler and
+// returns the typed (non-ctx, non-pusher) arguments plus the position of a
+// trailing stream pusher func among the parameters after ctx (-1 if none).
+// The pusher is not part of argTypes and must come last, so that position is
+// always len(argTypes).
+func validateHandlerMethod(mt reflect.Type) (argTypes []reflect.Type, streamIdx int, ok bool) {
+	if mt.NumIn() < 2 || mt.In(1) != typeOfContext {
+		return nil, -1, false
+	}
+	switch mt.NumOut() {
+	case 0:
+	case 2:
+		if mt.Out(1) != typeOfError {
+			return nil, -1, false
+		}
+		if out := mt.Out(0); out != typeOfBytes && out.Kind() != reflect.Ptr {
+			return nil, -1, false
+		}
+	default:
+		return nil, -1, false
+	}
+
+	streamIdx = -1
+	for i := 2; i < mt.NumIn(); i++ {
+		in := mt.In(i)
+		if in == typeOfPusher {
+			if i != mt.NumIn()-1 {
+				// a pusher func may only be the last argument
+				return nil, -1, false
+			}
+			streamIdx = i - 2
+			continue
+		}
+		if !isHandlerArg(in) {
+			return nil, -1, false
+		}
+		argTypes = append(argTypes, in)
+	}
+	return argTypes, streamIdx, true
+}
+
+// suitableHandlerMethods returns the exported methods of typ that satisfy
+// the shape documented on Service.ExtractHandler, keyed by their (optionally
+// translated) route name.
+func suitableHandlerMethods(typ reflect.Type, nameFunc func(string) string) map[string]*Handler {
+	methods := make(map[string]*Handler)
+	for m := 0; m < typ.NumMethod(); m++ {
+		method := typ.Method(m)
+		mn := method.Name
+		if !isExported(mn) {
+			continue
+		}
+		argTypes, streamIdx, ok := validateHandlerMethod(method.Type)
+		if !ok {
+			continue
+		}
+
+		handler := &Handler{
+			Method:    method,
+			NumArgs:   len(argTypes),
+			ArgTypes:  argTypes,
+			StreamIdx: streamIdx,
+		}
+		if len(argTypes) == 1 {
+			handler.Type = argTypes[0]
+			handler.IsRawArg = argTypes[0] == typeOfBytes
+		}
+
+		name := mn
+		if nameFunc != nil {
+			name = nameFunc(name)
+		}
+		methods[name] = handler
+	}
+	return methods
+}
+
+// suitableRemoteMethods returns the exported methods of typ that satisfy
+// the shape documented on Service.ExtractRemote, keyed by their (optionally
+// translated) route name.
+func suitableRemoteMethods(typ reflect.Type, nameFunc func(string) string) map[string]*Remote {
+	methods := make(map[string]*Remote)
+	for m := 0; m < typ.NumMethod(); m++ {
+		method := typ.Method(m)
+		mn := method.Name
+		if !isExported(mn) {
+			continue
+		}
+		mt := method.Type
+		if mt.NumOut() != 2 || mt.Out(1) != typeOfError {
+			continue
+		}
+		if mt.Out(0).Kind() != reflect.Ptr {
+			continue
+		}
+		if _, ok := reflect.New(mt.Out(0).Elem()).Interface().(proto.Message); !ok {
+			continue
+		}
+
+		remote := &Remote{
+			Method:  method,
+			HasArgs: mt.NumIn() > 1 && isHandlerArg(mt.In(1)),
+		}
+		if remote.HasArgs {
+			remote.Type = mt.In(1)
+		}
+
+		name := mn
+		if nameFunc != nil {
+			name = nameFunc(name)
+		}
+		methods[name] = remote
+	}
+	return methods
+}
@@ -23,9 +23,12 @@ package component
 import (
 	"errors"
 	"reflect"
+	"time"
 
+	"github.com/topfreegames/pitaya/breaker"
 	"github.com/topfreegames/pitaya/conn/message"
 	"github.com/topfreegames/pitaya/constants"
+	"github.com/topfreegames/pitaya/ratelimit"
 )
 
 type (
@@ -33,9 +36,14 @@ type (
 	Handler struct {
 		Receiver    reflect.Value  // receiver of method 反射获变量的值  reflect.ValueOf(xxx)
 		Method      reflect.Method // method stub
-		Type        reflect.Type   // low-level type of method 反射变量的类型 reflect.TypeOf(xxx)
+		Type        reflect.Type   // low-level type of method, only set when NumArgs == 1 反射变量的类型 reflect.TypeOf(xxx)
 		IsRawArg    bool           // whether the data need to serialize 是否未经序列化的消息
 		MessageType message.Type   // handler allowed message type (either request or notify) server接收的请求的客户端消息类型 request notify
+		NumArgs     int            // number of typed arguments the method takes, besides ctx and an optional stream push func
+		ArgTypes    []reflect.Type // low-level type of each typed argument, in declaration order
+		StreamIdx   int            // position, among the params after ctx, of a trailing func(proto.Message) error push param (not in ArgTypes, so always len(ArgTypes)); -1 if the handler has none
+		RateLimit   ratelimit.Limiter // per (route, session uid) token bucket; nil means unlimited
+		Breaker     *breaker.Breaker  // circuit breaker tripped by consecutive handler errors with ErrInternalCode; nil means none
 	}
 
 	//Remote represents remote's meta information.
@@ -82,9 +90,12 @@ func NewService(comp Component, opts []Option) *Service {
 // ExtractHandler extract the set of methods from the
 // receiver value which satisfy the following conditions:
 // - exported method of exported type
-// - one or two arguments
 // - the first argument is context.Context
-// - the second argument (if it exists) is []byte or a pointer
+// - zero or more following arguments, each []byte or a pointer, unmarshaled
+//   from a params array/object the same way JSON-RPC positional/named
+//   params are (see Handler.ArgTypes); the last of these may instead be a
+//   func(proto.Message) error, used to stream chunks back to the client
+//   under the same request ID before the handler returns (Handler.StreamIdx)
 // - zero or two outputs
 // - the first output is [] or a pointer
 // - the second output is an error
@@ -157,6 +168,41 @@ func (s *Service) ExtractRemote() error {
 	return nil
 }
 
+// WithRateLimit tags the handler with an in-process token bucket policy
+// (rps, burst), keyed per (route, session uid) and enforced directly in
+// processHandlerMessage before the before-pipeline even runs, not by a
+// pipeline handler itself (see component.Handler.RateLimit). Components
+// call this to override the default limits for a specific route, e.g.
+// `handlers["room.join"].WithRateLimit(10, 20)`.
+//
+// This is a post-registration mutator rather than a registration-time
+// Option (e.g. `component.RateLimit(rps, burst)` passed to NewService),
+// and there is no global default applied to handlers that never call it:
+// component.Option/options has no hook for per-handler config today, so
+// each handler opts in individually after ExtractHandler populates
+// s.Handlers.
+func (h *Handler) WithRateLimit(rps, burst int) *Handler {
+	h.RateLimit = ratelimit.NewLocalLimiter(ratelimit.Policy{RPS: rps, Burst: burst})
+	return h
+}
+
+// WithRateLimiter tags the handler with a caller-provided Limiter, e.g. one
+// backed by Redis so the limit applies cluster-wide across frontends
+// instead of per process.
+func (h *Handler) WithRateLimiter(l ratelimit.Limiter) *Handler {
+	h.RateLimit = l
+	return h
+}
+
+// WithCircuitBreaker tags the handler with a breaker that trips after
+// threshold consecutive errors with ErrInternalCode (any other outcome resets
+// the count) and stays open for cooldown before probing again. Like WithRateLimit, it is enforced directly in
+// processHandlerMessage, ahead of the before pipeline, not inside it.
+func (h *Handler) WithCircuitBreaker(threshold int, cooldown time.Duration) *Handler {
+	h.Breaker = breaker.New(threshold, cooldown)
+	return h
+}
+
 // ValidateMessageType validates a given message type against the handler's one
 // and returns an error if it is a mismatch and a boolean indicating if the caller should
 // exit in the presence of this error or not.
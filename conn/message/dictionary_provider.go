@@ -0,0 +1,114 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package message
+
+import (
+	"context"
+
+	"github.com/topfreegames/pitaya/logger"
+)
+
+// DictionaryProvider is implemented by anything that can hand out a
+// route<->code dictionary and optionally stream updates to it, so the
+// dictionary is no longer tied to the process lifetime of a single server.
+type DictionaryProvider interface {
+	// Load returns the current dictionary snapshot.
+	Load(ctx context.Context) (map[string]uint16, error)
+	// Watch returns a channel that receives a new dictionary snapshot every
+	// time the underlying source changes. Implementations that don't support
+	// live updates may return a nil channel.
+	Watch(ctx context.Context) <-chan map[string]uint16
+}
+
+// StartDictionaryProvider loads the initial dictionary from p and replaces
+// the package-level dictionary with it, then spawns a goroutine that applies
+// every subsequent update from p.Watch until ctx is canceled. Callers that
+// only need a one-shot load (e.g. reading a static file once at boot) can
+// simply call p.Load and ReplaceDictionary directly instead.
+func StartDictionaryProvider(ctx context.Context, p DictionaryProvider) error {
+	dict, err := p.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if err := ReplaceDictionary(dict); err != nil {
+		return err
+	}
+
+	updates := p.Watch(ctx)
+	if updates == nil {
+		return nil
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case dict, ok := <-updates:
+				if !ok {
+					return
+				}
+				if err := ReplaceDictionary(dict); err != nil {
+					logger.Log.Errorf("pitaya/message: failed to apply reloaded dictionary: %s", err.Error())
+					continue
+				}
+				logger.Log.Infof("pitaya/message: route dictionary reloaded, version=%d", GetDictionaryVersion())
+			}
+		}
+	}()
+
+	return nil
+}
+
+// StaticProvider is a DictionaryProvider over a fixed, in-memory dictionary.
+// It never emits updates; it exists so code that accepts a DictionaryProvider
+// keeps working for deployments that still want the old "set it once" model.
+type StaticProvider struct {
+	Dict map[string]uint16
+}
+
+// Load returns the static dictionary.
+func (s *StaticProvider) Load(ctx context.Context) (map[string]uint16, error) {
+	return s.Dict, nil
+}
+
+// Watch returns a nil channel since a StaticProvider never changes.
+func (s *StaticProvider) Watch(ctx context.Context) <-chan map[string]uint16 {
+	return nil
+}
+
+// ForceReload loads the dictionary from p and replaces the current one
+// right away, without waiting on its Watch channel. It's the entry point an
+// admin RPC handler can call to push a dictionary update out-of-band; no
+// such RPC is wired up here, nor is GetDictionaryVersion piggybacked onto a
+// handshake yet — both are left for the RPC/session layer that owns those
+// protocols to call into.
+func ForceReload(ctx context.Context, p DictionaryProvider) error {
+	dict, err := p.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if err := ReplaceDictionary(dict); err != nil {
+		return err
+	}
+	logger.Log.Infof("pitaya/message: route dictionary force-reloaded, version=%d", GetDictionaryVersion())
+	return nil
+}
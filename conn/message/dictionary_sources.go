@@ -0,0 +1,225 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package message
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// consulWatchRetryDelay is how long ConsulProvider.Watch waits after a
+// failed KV().Get before retrying, so an unreachable Consul agent doesn't
+// get hammered in a busy loop.
+const consulWatchRetryDelay = 2 * time.Second
+
+// FileProvider is a DictionaryProvider backed by a JSON file of
+// {"route": code, ...} on disk, reloaded whenever the file changes.
+type FileProvider struct {
+	Path string
+}
+
+// Load reads and parses the dictionary file.
+func (f *FileProvider) Load(ctx context.Context) (map[string]uint16, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	var dict map[string]uint16
+	if err := json.Unmarshal(data, &dict); err != nil {
+		return nil, err
+	}
+	return dict, nil
+}
+
+// Watch uses fsnotify to emit a fresh dictionary every time f.Path is
+// written to, until ctx is canceled.
+func (f *FileProvider) Watch(ctx context.Context) <-chan map[string]uint16 {
+	out := make(chan map[string]uint16)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		close(out)
+		return out
+	}
+	if err := watcher.Add(f.Path); err != nil {
+		watcher.Close()
+		close(out)
+		return out
+	}
+
+	go func() {
+		defer watcher.Close()
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if ev.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				dict, err := f.Load(ctx)
+				if err != nil {
+					continue
+				}
+				select {
+				case out <- dict:
+				case <-ctx.Done():
+					return
+				}
+			case <-watcher.Errors:
+				continue
+			}
+		}
+	}()
+
+	return out
+}
+
+// EtcdProvider is a DictionaryProvider backed by a single etcd key holding
+// the dictionary as JSON, watched via etcd's native watch API.
+type EtcdProvider struct {
+	Client *clientv3.Client
+	Key    string
+}
+
+// Load fetches and parses the dictionary key.
+func (e *EtcdProvider) Load(ctx context.Context) (map[string]uint16, error) {
+	resp, err := e.Client.Get(ctx, e.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return map[string]uint16{}, nil
+	}
+	var dict map[string]uint16
+	if err := json.Unmarshal(resp.Kvs[0].Value, &dict); err != nil {
+		return nil, err
+	}
+	return dict, nil
+}
+
+// Watch streams a new dictionary every time e.Key is updated in etcd.
+func (e *EtcdProvider) Watch(ctx context.Context) <-chan map[string]uint16 {
+	out := make(chan map[string]uint16)
+
+	go func() {
+		defer close(out)
+		wc := e.Client.Watch(ctx, e.Key)
+		for resp := range wc {
+			for _, ev := range resp.Events {
+				var dict map[string]uint16
+				if err := json.Unmarshal(ev.Kv.Value, &dict); err != nil {
+					continue
+				}
+				select {
+				case out <- dict:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// ConsulProvider is a DictionaryProvider backed by a single Consul KV entry
+// holding the dictionary as JSON, polled via Consul's blocking queries.
+type ConsulProvider struct {
+	Client *api.Client
+	Key    string
+}
+
+// Load fetches and parses the dictionary key.
+func (c *ConsulProvider) Load(ctx context.Context) (map[string]uint16, error) {
+	kv, _, err := c.Client.KV().Get(c.Key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if kv == nil {
+		return map[string]uint16{}, nil
+	}
+	var dict map[string]uint16
+	if err := json.Unmarshal(kv.Value, &dict); err != nil {
+		return nil, err
+	}
+	return dict, nil
+}
+
+// Watch long-polls Consul (blocking queries) for changes to c.Key until ctx
+// is canceled.
+func (c *ConsulProvider) Watch(ctx context.Context) <-chan map[string]uint16 {
+	out := make(chan map[string]uint16)
+
+	go func() {
+		defer close(out)
+		var waitIndex uint64
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			kv, meta, err := c.Client.KV().Get(c.Key, &api.QueryOptions{
+				WaitIndex: waitIndex,
+				Context:   ctx,
+			})
+			if err != nil {
+				select {
+				case <-time.After(consulWatchRetryDelay):
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			if meta != nil {
+				waitIndex = meta.LastIndex
+			}
+			if kv == nil {
+				continue
+			}
+
+			var dict map[string]uint16
+			if err := json.Unmarshal(kv.Value, &dict); err != nil {
+				continue
+			}
+			select {
+			case out <- dict:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,106 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package message
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileProviderLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dict.json")
+	if err := ioutil.WriteFile(path, []byte(`{"room.join":1}`), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	f := &FileProvider{Path: path}
+	dict, err := f.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if dict["room.join"] != 1 {
+		t.Fatalf("Load() = %+v, want room.join=1", dict)
+	}
+}
+
+func TestFileProviderWatchReloadsOnWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dict.json")
+	if err := ioutil.WriteFile(path, []byte(`{"room.join":1}`), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := &FileProvider{Path: path}
+	updates := f.Watch(ctx)
+
+	if err := ioutil.WriteFile(path, []byte(`{"room.join":2}`), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	select {
+	case dict, ok := <-updates:
+		if !ok {
+			t.Fatal("updates channel closed before emitting a reload")
+		}
+		if dict["room.join"] != 2 {
+			t.Fatalf("reloaded dict = %+v, want room.join=2", dict)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for FileProvider.Watch to reload on write")
+	}
+}
+
+func TestFileProviderWatchStopsOnContextCancel(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dict.json")
+	if err := ioutil.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &FileProvider{Path: path}
+	updates := f.Watch(ctx)
+	cancel()
+
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Fatal("expected updates channel to close, got a value instead")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for FileProvider.Watch to stop after context cancel")
+	}
+}
+
+func TestFileProviderWatchMissingFile(t *testing.T) {
+	f := &FileProvider{Path: filepath.Join(t.TempDir(), "does-not-exist.json")}
+	updates := f.Watch(context.Background())
+	if _, ok := <-updates; ok {
+		t.Fatal("expected Watch() to return a closed channel for a missing path")
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package message
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCodecJSONRPCUnmarshalRequest(t *testing.T) {
+	c := NewCodecJSONRPC()
+	m, err := c.Unmarshal([]byte(`{"jsonrpc":"2.0","method":"room.join","params":{"id":1},"id":7}`))
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if m.Type != Request || m.ID != 7 || m.Route != "room.join" {
+		t.Fatalf("got %+v", m)
+	}
+}
+
+func TestCodecJSONRPCUnmarshalNotify(t *testing.T) {
+	c := NewCodecJSONRPC()
+	m, err := c.Unmarshal([]byte(`{"jsonrpc":"2.0","method":"room.ping"}`))
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if m.Type != Notify || m.Route != "room.ping" {
+		t.Fatalf("got %+v", m)
+	}
+}
+
+func TestCodecJSONRPCMarshalResult(t *testing.T) {
+	c := NewCodecJSONRPC()
+	m := &Message{Type: Response, ID: 7, Data: []byte(`{"ok":true}`)}
+	out, err := c.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var resp jsonRPCResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("re-parsing marshaled response: %v", err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("Error = %+v, want nil", resp.Error)
+	}
+	if string(resp.Result) != `{"ok":true}` {
+		t.Fatalf("Result = %s, want {\"ok\":true}", resp.Result)
+	}
+}
+
+// TestCodecJSONRPCMarshalStructuredError is the round trip the structured
+// error taxonomy (Message.Error) depends on: a handler error framed via
+// message.NewError must survive Marshal with its Code/Message/Metadata
+// intact, not collapse to an empty {"code":-32603,"message":""} the way it
+// did before Marshal was taught to read m.Error.
+func TestCodecJSONRPCMarshalStructuredError(t *testing.T) {
+	c := NewCodecJSONRPC()
+	m := NewError("PIT-404", "route not found", map[string]string{"route": "room.join"})
+	m.ID = 7
+	m.Type = Response
+
+	out, err := c.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var resp jsonRPCResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("re-parsing marshaled response: %v", err)
+	}
+	if resp.Error == nil {
+		t.Fatal("Error = nil, want non-nil")
+	}
+	if resp.Error.Code != JSONRPCMethodNotFound {
+		t.Errorf("Error.Code = %d, want %d", resp.Error.Code, JSONRPCMethodNotFound)
+	}
+	if resp.Error.Message != "route not found" {
+		t.Errorf("Error.Message = %q, want %q", resp.Error.Message, "route not found")
+	}
+	if resp.Error.Data["route"] != "room.join" {
+		t.Errorf("Error.Data[route] = %q, want %q", resp.Error.Data["route"], "room.join")
+	}
+}
+
+func TestCodecJSONRPCMarshalLegacyDataError(t *testing.T) {
+	c := NewCodecJSONRPC()
+	m := &Message{Type: Response, ID: 7, Err: true, Data: []byte(`{"code":"PIT-400","msg":"bad request"}`)}
+
+	out, err := c.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var resp jsonRPCResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("re-parsing marshaled response: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != JSONRPCInvalidParams || resp.Error.Message != "bad request" {
+		t.Fatalf("got %+v", resp.Error)
+	}
+}
+
+func TestCodecJSONRPCMarshalPush(t *testing.T) {
+	c := NewCodecJSONRPC()
+	m := &Message{Type: Push, Route: "room.update", Data: []byte(`{"x":1}`)}
+	out, err := c.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var resp jsonRPCResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("re-parsing marshaled push: %v", err)
+	}
+	if resp.Method != "room.update" || string(resp.Result) != `{"x":1}` {
+		t.Fatalf("got %+v", resp)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 )
 
 // Type represents the type of message, which could be Request/Notify/Response/Push
@@ -53,9 +54,15 @@ var types = map[Type]string{
 	Push:     "Push",
 }
 
+// routesCodesMutex guards routes and codes, the route<->code dictionary
+// used to compress/decompress routes on the wire, so it can be swapped
+// atomically while requests are being encoded/decoded.
+var routesCodesMutex sync.RWMutex
+
 var (
-	routes = make(map[string]uint16) // route map to code
-	codes  = make(map[uint16]string) // code map to route
+	routes      = make(map[string]uint16) // route map to code
+	codes       = make(map[uint16]string) // code map to route
+	dictVersion uint64                    // bumped every time the dictionary is swapped
 )
 
 // Errors that could be occurred in message codec
@@ -98,7 +105,21 @@ type Message struct {
 	Route      string // route for locating service
 	Data       []byte // payload
 	compressed bool   // is message compressed
-	Err        bool   // is an error message
+	Err        bool   // is an error message (wire-compat with the existing errorMask bit)
+
+	// Error, when Err is true, carries the structured error info so callers
+	// (e.g. CodecJSONRPC.Marshal) can let clients branch on Code without
+	// unmarshaling a business type first. It is nil for messages that only
+	// ever had a business-level error payload in Data.
+	Error *Error
+}
+
+// Error is the structured payload carried by an error Message, letting
+// clients branch on Code without unmarshaling a business type first.
+type Error struct {
+	Code     string            // machine-readable error code, e.g. "PIT-404"
+	Message  string            // human-readable error message
+	Metadata map[string]string // optional extra key/value context
 }
 
 // New returns a new message instance
@@ -110,6 +131,14 @@ func New(err ...bool) *Message {
 	return m
 }
 
+// NewError returns a new error Message carrying structured error info.
+func NewError(code, msg string, metadata map[string]string) *Message {
+	return &Message{
+		Err:   true,
+		Error: &Error{Code: code, Message: msg, Metadata: metadata},
+	}
+}
+
 // String, implementation of fmt.Stringer interface
 func (m *Message) String() string {
 	return fmt.Sprintf("Type: %s, ID: %d, Route: %s, Compressed: %t, Error: %t, Data: %v, BodyLength: %d",
@@ -131,12 +160,17 @@ func invalidType(t Type) bool {
 
 }
 
-// SetDictionary set routes map which be used to compress route.
+// SetDictionary set routes map which be used to compress route. It merges
+// dict into the current dictionary in place; to fully replace the dictionary
+// (e.g. when reloading from a DictionaryProvider) use ReplaceDictionary.
 func SetDictionary(dict map[string]uint16) error {
 	if dict == nil {
 		return nil
 	}
 
+	routesCodesMutex.Lock()
+	defer routesCodesMutex.Unlock()
+
 	for route, code := range dict {
 		r := strings.TrimSpace(route) //去掉开头结尾的空格
 
@@ -153,13 +187,61 @@ func SetDictionary(dict map[string]uint16) error {
 		routes[r] = code
 		codes[code] = r
 	}
+	dictVersion++
+
+	return nil
+}
+
+// ReplaceDictionary atomically swaps the whole routes/codes dictionary for
+// dict, bumping the dictionary version. Unlike SetDictionary it does not
+// merge with the previous dictionary, so it is safe to call repeatedly with
+// a DictionaryProvider's latest snapshot.
+func ReplaceDictionary(dict map[string]uint16) error {
+	newRoutes := make(map[string]uint16, len(dict))
+	newCodes := make(map[uint16]string, len(dict))
+
+	for route, code := range dict {
+		r := strings.TrimSpace(route)
+
+		if _, ok := newRoutes[r]; ok {
+			return fmt.Errorf("duplicated route(route: %s, code: %d)", r, code)
+		}
+		if _, ok := newCodes[code]; ok {
+			return fmt.Errorf("duplicated route(route: %s, code: %d)", r, code)
+		}
+
+		newRoutes[r] = code
+		newCodes[code] = r
+	}
+
+	routesCodesMutex.Lock()
+	routes = newRoutes
+	codes = newCodes
+	dictVersion++
+	routesCodesMutex.Unlock()
 
 	return nil
 }
 
 // GetDictionary gets the routes map which is used to compress route.
 func GetDictionary() map[string]uint16 {
-	return routes
+	routesCodesMutex.RLock()
+	defer routesCodesMutex.RUnlock()
+
+	dict := make(map[string]uint16, len(routes))
+	for r, c := range routes {
+		dict[r] = c
+	}
+	return dict
+}
+
+// GetDictionaryVersion returns a counter bumped every time the dictionary is
+// replaced or merged, so clients can tell handshake-piggybacked dictionaries
+// apart from the one they already cached.
+func GetDictionaryVersion() uint64 {
+	routesCodesMutex.RLock()
+	defer routesCodesMutex.RUnlock()
+	return dictVersion
 }
 
 func (t *Type) String() string {
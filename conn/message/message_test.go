@@ -0,0 +1,111 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package message
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestReplaceDictionaryRejectsDuplicateRoute(t *testing.T) {
+	defer ReplaceDictionary(nil)
+
+	if err := ReplaceDictionary(map[string]uint16{"room.join": 1}); err != nil {
+		t.Fatalf("ReplaceDictionary() error = %v", err)
+	}
+	before := GetDictionaryVersion()
+
+	err := ReplaceDictionary(map[string]uint16{"room.join": 1, "room.join ": 2})
+	if err == nil {
+		t.Fatal("ReplaceDictionary() error = nil, want duplicated route error")
+	}
+	if got := GetDictionaryVersion(); got != before {
+		t.Fatalf("GetDictionaryVersion() = %d, want %d (dictionary should be left untouched on error)", got, before)
+	}
+}
+
+func TestReplaceDictionaryRejectsDuplicateCode(t *testing.T) {
+	defer ReplaceDictionary(nil)
+
+	err := ReplaceDictionary(map[string]uint16{"room.join": 1, "room.leave": 1})
+	if err == nil {
+		t.Fatal("ReplaceDictionary() error = nil, want duplicated route error")
+	}
+}
+
+func TestReplaceDictionarySwapsAtomically(t *testing.T) {
+	defer ReplaceDictionary(nil)
+
+	if err := ReplaceDictionary(map[string]uint16{"room.join": 1}); err != nil {
+		t.Fatalf("ReplaceDictionary() error = %v", err)
+	}
+	firstVersion := GetDictionaryVersion()
+
+	if err := ReplaceDictionary(map[string]uint16{"room.leave": 2}); err != nil {
+		t.Fatalf("ReplaceDictionary() error = %v", err)
+	}
+
+	dict := GetDictionary()
+	if _, ok := dict["room.join"]; ok {
+		t.Fatal(`GetDictionary() still has "room.join", want it replaced, not merged`)
+	}
+	if _, ok := dict["room.leave"]; !ok {
+		t.Fatal(`GetDictionary() missing "room.leave"`)
+	}
+	if got := GetDictionaryVersion(); got != firstVersion+1 {
+		t.Fatalf("GetDictionaryVersion() = %d, want %d", got, firstVersion+1)
+	}
+}
+
+// TestReplaceDictionaryConcurrentReaders swaps the dictionary repeatedly
+// while readers call GetDictionary/GetDictionaryVersion concurrently, so
+// -race can catch routesCodesMutex being held too narrowly.
+func TestReplaceDictionaryConcurrentReaders(t *testing.T) {
+	defer ReplaceDictionary(nil)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					_ = GetDictionary()
+					_ = GetDictionaryVersion()
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		if err := ReplaceDictionary(map[string]uint16{"room.join": uint16(i)}); err != nil {
+			t.Fatalf("ReplaceDictionary() error = %v", err)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+}
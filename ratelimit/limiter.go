@@ -0,0 +1,88 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package ratelimit implements a token-bucket rate limiter keyed by an
+// arbitrary string (typically "route:uid"), with an in-memory backend for a
+// single process and an optional Redis-backed one for cluster-wide limits.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy configures a token bucket: rps tokens are added per second, up to
+// burst tokens held at once.
+type Policy struct {
+	RPS   int
+	Burst int
+}
+
+// Limiter decides whether a request identified by key should be allowed.
+type Limiter interface {
+	Allow(key string) bool
+}
+
+// bucket is a single key's token-bucket state.
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// localLimiter is a single-process, in-memory Limiter.
+type localLimiter struct {
+	policy  Policy
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewLocalLimiter returns a Limiter enforcing policy per key within this
+// process only.
+func NewLocalLimiter(policy Policy) Limiter {
+	return &localLimiter{
+		policy:  policy,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (l *localLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.policy.Burst), lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens += elapsed * float64(l.policy.RPS)
+	if b.tokens > float64(l.policy.Burst) {
+		b.tokens = float64(l.policy.Burst)
+	}
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
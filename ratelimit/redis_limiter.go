@@ -0,0 +1,85 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// redisLimiter is a cluster-wide Limiter backed by a Redis Lua script so the
+// check-and-decrement of the bucket stays atomic across frontends.
+type redisLimiter struct {
+	client *redis.Client
+	policy Policy
+	prefix string
+}
+
+// tokenBucketScript refills the bucket based on elapsed time since the last
+// call, then atomically consumes one token if available. KEYS[1] is the
+// bucket key; ARGV is rps, burst, now (unix nanos).
+var tokenBucketScript = redis.NewScript(`
+local tokens_key = KEYS[1] .. ":tokens"
+local ts_key = KEYS[1] .. ":ts"
+local rps = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local tokens = tonumber(redis.call("GET", tokens_key))
+local last = tonumber(redis.call("GET", ts_key))
+if tokens == nil then tokens = burst end
+if last == nil then last = now end
+
+local elapsed = (now - last) / 1e9
+tokens = math.min(burst, tokens + elapsed * rps)
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call("SET", tokens_key, tokens, "EX", 3600)
+redis.call("SET", ts_key, now, "EX", 3600)
+return allowed
+`)
+
+// NewRedisLimiter returns a Limiter enforcing policy across every process
+// sharing client, under keys prefixed with prefix.
+func NewRedisLimiter(client *redis.Client, policy Policy, prefix string) Limiter {
+	return &redisLimiter{client: client, policy: policy, prefix: prefix}
+}
+
+func (l *redisLimiter) Allow(key string) bool {
+	res, err := tokenBucketScript.Run(
+		l.client,
+		[]string{fmt.Sprintf("%s:%s", l.prefix, key)},
+		l.policy.RPS, l.policy.Burst, time.Now().UnixNano(),
+	).Result()
+	if err != nil {
+		// fail open: a Redis hiccup shouldn't take handlers down
+		return true
+	}
+	allowed, ok := res.(int64)
+	return !ok || allowed == 1
+}
@@ -0,0 +1,78 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/topfreegames/pitaya/metrics"
+)
+
+// handlerGuardCounters tallies how many times the rate-limit/breaker checks
+// in processHandlerMessage allowed a call through, rate-limited it, or
+// short-circuited it on a tripped breaker, keyed by route so
+// reportHandlerGuardEvent can publish a running total per route instead of
+// one big undifferentiated number.
+var (
+	metricsReportersMu sync.RWMutex
+	metricsReporters   []metrics.Reporter
+
+	handlerGuardCountersMu sync.Mutex
+	handlerGuardCounters   = map[string]*uint64{}
+)
+
+// SetMetricsReporters registers the reporters processHandlerMessage
+// publishes the rate-limit/breaker guard counters (allowed/limited/tripped)
+// through. It mirrors how cluster.NewGRPCClient takes its metricsReporters
+// at construction time; call it once during server setup.
+func SetMetricsReporters(reporters []metrics.Reporter) {
+	metricsReportersMu.Lock()
+	defer metricsReportersMu.Unlock()
+	metricsReporters = reporters
+}
+
+// reportHandlerGuardEvent increments the running count for (route, outcome)
+// and republishes the running total through ReportGauge, the same reporter
+// call cluster.GRPCClient.reportBreakerState uses for breaker state.
+// outcome is one of "allowed", "limited" or "tripped".
+func reportHandlerGuardEvent(route, outcome string) {
+	metricsReportersMu.RLock()
+	reporters := metricsReporters
+	metricsReportersMu.RUnlock()
+	if len(reporters) == 0 {
+		return
+	}
+
+	key := route + ":" + outcome
+	handlerGuardCountersMu.Lock()
+	counter, ok := handlerGuardCounters[key]
+	if !ok {
+		counter = new(uint64)
+		handlerGuardCounters[key] = counter
+	}
+	handlerGuardCountersMu.Unlock()
+	count := atomic.AddUint64(counter, 1)
+
+	for _, r := range reporters {
+		r.ReportGauge("handler_guard", map[string]string{"route": route, "outcome": outcome}, float64(count))
+	}
+}
@@ -22,21 +22,26 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"runtime"
 
 	"github.com/golang/protobuf/proto"
+	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/topfreegames/pitaya/component"
 	"github.com/topfreegames/pitaya/conn/message"
 	"github.com/topfreegames/pitaya/constants"
 	e "github.com/topfreegames/pitaya/errors"
+	"github.com/topfreegames/pitaya/ratelimit"
 	"github.com/topfreegames/pitaya/logger"
 	"github.com/topfreegames/pitaya/pipeline"
 	"github.com/topfreegames/pitaya/protos"
 	"github.com/topfreegames/pitaya/route"
 	"github.com/topfreegames/pitaya/serialize"
 	"github.com/topfreegames/pitaya/session"
+	"github.com/topfreegames/pitaya/tracing"
 	"github.com/topfreegames/pitaya/util"
 )
 
@@ -53,20 +58,80 @@ func getHandler(rt *route.Route) (*component.Handler, error) {
 }
 
 //根据component.Handler中参数的去反序列化消息
-func unmarshalHandlerArg(handler *component.Handler, serializer serialize.Serializer, payload []byte) (interface{}, error) {
+// unmarshalHandlerArgs decodes payload into the typed arguments described by
+// handler.ArgTypes, returning them as reflect.Values ready to be appended to
+// the reflect.Call args slice (right after receiver and ctx). A handler with
+// a single typed argument keeps the historical behaviour (raw bytes or a
+// whole-payload unmarshal, through whatever serializer the caller uses); a
+// handler with more than one decodes payload as a JSON-RPC-style params
+// array, one element per ArgTypes entry. The outer array framing is always
+// decoded as JSON regardless of serializer, since splitting it into
+// per-element raw bytes relies on json.RawMessage, which has no equivalent
+// in the pluggable serialize.Serializer interface — so multi-arg handlers
+// are only supported when serializer is the JSON one; anything else is
+// rejected up front instead of failing confusingly on a JSON-shaped payload.
+func unmarshalHandlerArgs(handler *component.Handler, serializer serialize.Serializer, payload []byte) ([]reflect.Value, error) {
+	if handler.NumArgs == 0 {
+		return nil, nil
+	}
+
 	if handler.IsRawArg {
-		return payload, nil
+		return []reflect.Value{reflect.ValueOf(payload)}, nil
 	}
 
-	var arg interface{}
-	if handler.Type != nil {
-		arg = reflect.New(handler.Type.Elem()).Interface()
-		err := serializer.Unmarshal(payload, arg)
-		if err != nil {
+	if handler.NumArgs == 1 {
+		if handler.Type == nil {
+			return nil, nil
+		}
+		arg := reflect.New(handler.Type.Elem()).Interface()
+		if err := serializer.Unmarshal(payload, arg); err != nil {
 			return nil, err
 		}
+		return []reflect.Value{reflect.ValueOf(arg)}, nil
+	}
+
+	// serialize/json.Serializer.GetName() is "json"; any other serializer
+	// can't give us per-element raw framing (see the doc comment above).
+	if name := serializer.GetName(); name != "json" {
+		return nil, fmt.Errorf("pitaya/handler: multi-arg handlers require the JSON serializer, got %q", name)
+	}
+
+	var rawParams []json.RawMessage
+	if err := json.Unmarshal(payload, &rawParams); err != nil {
+		return nil, fmt.Errorf("pitaya/handler: multi-arg params must be a JSON array: %w", err)
+	}
+	if len(rawParams) != len(handler.ArgTypes) {
+		return nil, fmt.Errorf("pitaya/handler: expected %d params, got %d", len(handler.ArgTypes), len(rawParams))
+	}
+
+	args := make([]reflect.Value, len(handler.ArgTypes))
+	for i, t := range handler.ArgTypes {
+		if t.Kind() == reflect.Slice {
+			// a []byte argument gets its params element passed through raw
+			args[i] = reflect.ValueOf([]byte(rawParams[i]))
+			continue
+		}
+		arg := reflect.New(t.Elem()).Interface()
+		if err := serializer.Unmarshal(rawParams[i], arg); err != nil {
+			return nil, err
+		}
+		args[i] = reflect.ValueOf(arg)
+	}
+	return args, nil
+}
+
+// newStreamPusher builds the func(proto.Message) error argument passed to
+// handlers whose last parameter is a stream push callback (Handler.StreamIdx
+// >= 0). Each call responds to the original request id without finishing it,
+// letting the handler send progress updates ahead of its final return value.
+func newStreamPusher(ctx context.Context, serializer serialize.Serializer, sess *session.Session, mid uint) func(proto.Message) error {
+	return func(chunk proto.Message) error {
+		data, err := serializer.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		return sess.ResponseMID(ctx, mid, data, false)
 	}
-	return arg, nil
 }
 
 func unmarshalRemoteArg(remote *component.Remote, payload []byte) (interface{}, error) {
@@ -97,13 +162,24 @@ func getMsgType(msgTypeIface interface{}) (message.Type, error) {
 	return msgType, nil
 }
 
+// pipelineHandlerName returns h's function name (e.g.
+// "mygame/pipelines.ValidateSession") for tagging its pipeline span, so
+// distinct stages are distinguishable in a trace instead of all showing up
+// as "Before Pipeline"/"After Pipeline".
+func pipelineHandlerName(h interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+}
+
 //对参数进行一些列的管道函数处理
 func executeBeforePipeline(ctx context.Context, data interface{}) (interface{}, error) {
 	var err error
 	res := data
 	if len(pipeline.BeforeHandler.Handlers) > 0 {
+		parent, _ := tracing.ExtractSpan(ctx)
 		for _, h := range pipeline.BeforeHandler.Handlers {
-			res, err = h(ctx, res)
+			hCtx := tracing.StartSpan(ctx, "Before Pipeline", opentracing.Tags{"pipeline.handler": pipelineHandlerName(h)}, parent)
+			res, err = h(hCtx, res)
+			tracing.FinishSpan(hCtx, err)
 			if err != nil {
 				logger.Log.Debugf("pitaya/handler: broken pipeline: %s", err.Error())
 				return res, err
@@ -116,13 +192,32 @@ func executeBeforePipeline(ctx context.Context, data interface{}) (interface{},
 func executeAfterPipeline(ctx context.Context, res interface{}, err error) (interface{}, error) {
 	ret := res
 	if len(pipeline.AfterHandler.Handlers) > 0 {
+		parent, _ := tracing.ExtractSpan(ctx)
 		for _, h := range pipeline.AfterHandler.Handlers {
-			ret, err = h(ctx, ret, err)
+			hCtx := tracing.StartSpan(ctx, "After Pipeline", opentracing.Tags{"pipeline.handler": pipelineHandlerName(h)}, parent)
+			ret, err = h(hCtx, ret, err)
+			tracing.FinishSpan(hCtx, err)
 		}
 	}
 	return ret, err
 }
 
+// ErrorToMessage turns an error returned by processHandlerMessage into an
+// error message.Message carrying a structured message.Error, so the caller
+// framing the response can let clients branch on Code without unmarshaling
+// a business type. A plain (non-*e.Error) error still gets framed as an
+// error Message, but with ErrUnknownCode since it carries no Pitaya code.
+func ErrorToMessage(id uint, err error) *message.Message {
+	pitErr, ok := err.(*e.Error)
+	if !ok {
+		pitErr = e.NewError(err, e.ErrUnknownCode)
+	}
+	m := message.NewError(pitErr.Code, pitErr.Message, pitErr.Metadata)
+	m.ID = id
+	m.Type = message.Response
+	return m
+}
+
 func serializeReturn(ser serialize.Serializer, ret interface{}) ([]byte, error) {
 	res, err := util.SerializeOrRaw(ser, ret)
 	if err != nil {
@@ -137,6 +232,8 @@ func serializeReturn(ser serialize.Serializer, ret interface{}) ([]byte, error)
 }
 
 //根据Route查找component.Handler利用反射机制调用handler，同时将消息和ctx作为参数
+// mid is the original message id; handlers with a stream push parameter use
+// it to respond with chunks ahead of their final return value.
 func processHandlerMessage(
 	ctx context.Context,
 	rt *route.Route, //路由信息
@@ -144,6 +241,7 @@ func processHandlerMessage(
 	session *session.Session,
 	data []byte, //消息体解压后的原始二进制
 	msgTypeIface interface{}, //message.Type
+	mid uint, //original request id, needed to stream chunks back under it
 	remote bool, //是否远程服务器
 ) ([]byte, error) {
 	//上下中添加session 和日志处理
@@ -154,6 +252,26 @@ func processHandlerMessage(
 	ctx = context.WithValue(ctx, constants.SessionCtxKey, session)
 	ctx = util.CtxWithDefaultLogger(ctx, rt.String(), session.UID())
 
+	// start a span for this handler invocation, chaining off any span
+	// already attached to ctx. For a local call that's the same in-process
+	// request; for one arriving over a cluster RPC it's always nil today,
+	// since nothing extracts a SpanContext from the incoming RPC and
+	// attaches it here (see tracing.ExtractFromCarrier) — this always
+	// starts a new trace for remote calls rather than chaining off the
+	// caller's span.
+	parent, err := tracing.ExtractSpan(ctx)
+	if err != nil {
+		logger.Log.Warnf("pitaya/handler: failed to retrieve parent span: %s", err.Error())
+	}
+	tags := opentracing.Tags{
+		"span.kind":    "server",
+		"msg.route":    rt.String(),
+		"msg.uid":      session.UID(),
+		"msg.isRemote": remote,
+	}
+	ctx = tracing.StartSpan(ctx, "Handler Call", tags, parent)
+	defer func() { tracing.FinishSpan(ctx, err) }()
+
 	//根据Route获取hander
 	h, err := getHandler(rt)
 	if err != nil {
@@ -165,6 +283,33 @@ func processHandlerMessage(
 	if err != nil {
 		return nil, e.NewError(err, e.ErrInternalCode)
 	}
+	opentracing.Tag{Key: "msg.type", Value: msgType.String()}.Set(tracing.SpanFromContext(ctx))
+
+	// rate limit and circuit breaker checks happen before any unmarshaling/
+	// pipeline work, so a throttled or tripped handler is as cheap as possible.
+	// err is assigned (not just returned) so the deferred tracing.FinishSpan
+	// above sees it and marks the span as an error.
+	if h.RateLimit != nil && !h.RateLimit.Allow(fmt.Sprintf("%s:%s", rt.Short(), session.UID())) {
+		reportHandlerGuardEvent(rt.Short(), "limited")
+		err = e.NewError(constants.ErrRateLimitExceeded, e.ErrRateLimitExceededCode)
+		return nil, err
+	}
+	if h.Breaker != nil && !h.Breaker.Allow() {
+		reportHandlerGuardEvent(rt.Short(), "tripped")
+		err = e.NewError(constants.ErrBreakerOpen, e.ErrServiceUnavailableCode)
+		return nil, err
+	}
+	if h.RateLimit != nil || h.Breaker != nil {
+		reportHandlerGuardEvent(rt.Short(), "allowed")
+	}
+	// a call the breaker let through that returns before reaching the
+	// handler is reported as a success, so a half-open probe always resolves
+	breakerReported := false
+	defer func() {
+		if h.Breaker != nil && !breakerReported {
+			h.Breaker.Success()
+		}
+	}()
 
 	logger := ctx.Value(constants.LoggerCtxKey).(logger.Logger)
 	exit, err := h.ValidateMessageType(msgType)
@@ -174,29 +319,47 @@ func processHandlerMessage(
 		logger.Warnf("invalid message type, error: %s", err.Error())
 	}
 
-	// First unmarshal the handler arg that will be passed to
+	// First unmarshal the handler args that will be passed to
 	// both handler and pipeline functions
 	//根据component.Handler中参数的去反序列化消息
-	arg, err := unmarshalHandlerArg(h, serializer, data)
+	handlerArgs, err := unmarshalHandlerArgs(h, serializer, data)
 	if err != nil {
 		return nil, e.NewError(err, e.ErrBadRequestCode)
 	}
 
-	//处理参数
-	if arg, err = executeBeforePipeline(ctx, arg); err != nil {
+	//处理参数 (the pipeline still only sees the first typed arg, for
+	//backwards compatibility with existing pipeline functions)
+	var pipelineArg interface{}
+	if len(handlerArgs) > 0 {
+		pipelineArg = handlerArgs[0].Interface()
+	}
+	if pipelineArg, err = executeBeforePipeline(ctx, pipelineArg); err != nil {
 		return nil, err
 	}
+	if len(handlerArgs) > 0 {
+		handlerArgs[0] = reflect.ValueOf(pipelineArg)
+	}
 
 	//利用反射进行handler调用
 	logger.Debugf("SID=%d, Data=%s", session.ID(), data)
 
 	//构建调用参数
 	args := []reflect.Value{h.Receiver, reflect.ValueOf(ctx)}
-	if arg != nil {
-		args = append(args, reflect.ValueOf(arg))
+	args = append(args, handlerArgs...)
+	if h.StreamIdx >= 0 {
+		pusher := newStreamPusher(ctx, serializer, session, mid)
+		args = append(args, reflect.ValueOf(pusher))
 	}
 
 	resp, err := util.Pcall(h.Method, args)
+	if h.Breaker != nil {
+		breakerReported = true
+		if pitErr, ok := err.(*e.Error); ok && pitErr.Code == e.ErrInternalCode {
+			h.Breaker.Failure()
+		} else {
+			h.Breaker.Success()
+		}
+	}
 	if remote && msgType == message.Notify {
 		// This is a special case and should only happen with nats rpc client
 		// because we used nats request we have to answer to it or else a timeout
@@ -0,0 +1,91 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tracing
+
+import (
+	"fmt"
+	"io"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	zipkinot "github.com/openzipkin-contrib/zipkin-go-opentracing"
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+)
+
+// buildTracer dispatches to the concrete collector implementation configured
+// in c.Collector, returning the resulting opentracing.Tracer and an io.Closer
+// that flushes/closes the underlying reporter on shutdown.
+func buildTracer(c *Config) (opentracing.Tracer, io.Closer, error) {
+	switch c.Collector {
+	case CollectorJaeger:
+		return buildJaegerTracer(c)
+	case CollectorZipkin:
+		return buildZipkinTracer(c)
+	default:
+		return nil, nil, fmt.Errorf("unknown tracing collector: %s", c.Collector)
+	}
+}
+
+func buildJaegerTracer(c *Config) (opentracing.Tracer, io.Closer, error) {
+	jcfg := jaegercfg.Configuration{
+		ServiceName: c.ServiceName,
+		Sampler: &jaegercfg.SamplerConfig{
+			Type:  "probabilistic",
+			Param: c.SamplerRate,
+		},
+		Reporter: &jaegercfg.ReporterConfig{
+			LocalAgentHostPort: c.ConnectString,
+		},
+	}
+	return jcfg.NewTracer()
+}
+
+// buildZipkinTracer builds a native *zipkin.Tracer reporting to an HTTP
+// collector at c.ConnectString, then wraps it with zipkinot.Wrap so it
+// satisfies opentracing.Tracer like the jaeger tracer does.
+func buildZipkinTracer(c *Config) (opentracing.Tracer, io.Closer, error) {
+	reporter := zipkinhttp.NewReporter(c.ConnectString)
+
+	endpoint, err := zipkin.NewEndpoint(c.ServiceName, c.SpanHost)
+	if err != nil {
+		reporter.Close()
+		return nil, nil, err
+	}
+
+	sampler, err := zipkin.NewBoundarySampler(c.SamplerRate, 0)
+	if err != nil {
+		reporter.Close()
+		return nil, nil, err
+	}
+
+	nativeTracer, err := zipkin.NewTracer(
+		reporter,
+		zipkin.WithLocalEndpoint(endpoint),
+		zipkin.WithSampler(sampler),
+	)
+	if err != nil {
+		reporter.Close()
+		return nil, nil, err
+	}
+
+	return zipkinot.Wrap(nativeTracer), reporter, nil
+}
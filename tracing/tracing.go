@@ -0,0 +1,190 @@
+// Copyright (c) TFG Co. All Rights Reserved.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package tracing wraps opentracing.GlobalTracer so that handler and remote
+// pipelines, as well as cluster RPCs, can all create/propagate spans the same
+// way without every caller touching the opentracing API directly.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	opentracing "github.com/opentracing/opentracing-go"
+
+	"github.com/topfreegames/pitaya/config"
+	"github.com/topfreegames/pitaya/logger"
+)
+
+// CollectorType identifies which tracing backend to report spans to.
+type CollectorType string
+
+// Supported collector types, mirroring the cfg keys under pitaya.tracing.*
+const (
+	CollectorJaeger CollectorType = "jaeger"
+	CollectorZipkin CollectorType = "zipkin"
+)
+
+// Config holds the settings needed to build and register a GlobalTracer.
+// It is read from pitaya.tracing.* the same way fabio reads config.Tracing.
+type Config struct {
+	ServiceName   string        // pitaya.tracing.servicename
+	Collector     CollectorType // pitaya.tracing.collector
+	ConnectString string        // pitaya.tracing.connectstring, e.g. agent host:port or HTTP collector URL
+	SamplerRate   float64       // pitaya.tracing.samplerrate, 0..1
+	SpanHost      string        // pitaya.tracing.spanhost, used to tag local.host on every span
+}
+
+// NewConfig reads tracing settings out of a *config.Config, applying the same
+// defaults the rest of pitaya uses for optional subsystems.
+func NewConfig(cfg *config.Config) *Config {
+	c := &Config{
+		ServiceName:   cfg.GetString("pitaya.tracing.servicename"),
+		Collector:     CollectorType(cfg.GetString("pitaya.tracing.collector")),
+		ConnectString: cfg.GetString("pitaya.tracing.connectstring"),
+		SamplerRate:   samplerRateFromConfig(cfg),
+		SpanHost:      cfg.GetString("pitaya.tracing.spanhost"),
+	}
+	if c.SamplerRate == 0 {
+		c.SamplerRate = 1.0
+	}
+	return c
+}
+
+// samplerRateFromConfig reads pitaya.tracing.samplerrate as a float64.
+// *config.Config has no GetFloat64 of its own, so this parses the string
+// form the same way GRPCClient.configure does for its breaker ratio.
+func samplerRateFromConfig(cfg *config.Config) float64 {
+	rate, err := strconv.ParseFloat(cfg.GetString("pitaya.tracing.samplerrate"), 64)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// InitGlobalTracer builds a tracer for the configured collector and installs
+// it as opentracing.GlobalTracer(). When no collector is configured it does
+// nothing and returns a no-op cleanup func and a nil error, so tracing stays
+// entirely opt-in.
+func InitGlobalTracer(c *Config) (func(), error) {
+	if c == nil || c.Collector == "" {
+		return func() {}, nil
+	}
+
+	tracer, closer, err := buildTracer(c)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: failed to build %s tracer: %w", c.Collector, err)
+	}
+
+	opentracing.SetGlobalTracer(tracer)
+	logger.Log.Infof("[tracing] %s tracer initialized for service %s", c.Collector, c.ServiceName)
+
+	return func() {
+		if closer != nil {
+			closer.Close()
+		}
+	}, nil
+}
+
+// StartSpan starts a new span named operationName, tagged with tags, as a
+// child of parent (which may be nil for a root span), and returns a new ctx
+// carrying it. Use FinishSpan with the returned ctx to close it.
+func StartSpan(
+	ctx context.Context,
+	operationName string,
+	tags opentracing.Tags,
+	parent opentracing.SpanContext,
+) context.Context {
+	var span opentracing.Span
+	opts := []opentracing.StartSpanOption{tags}
+	if parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent))
+	}
+	span = opentracing.GlobalTracer().StartSpan(operationName, opts...)
+	return opentracing.ContextWithSpan(ctx, span)
+}
+
+// FinishSpan finishes the span stored in ctx, if any, tagging it as an error
+// when err is non-nil.
+func FinishSpan(ctx context.Context, err error) {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.SetTag("error", true)
+		span.SetTag("error.message", err.Error())
+	}
+	span.Finish()
+}
+
+// ExtractSpan returns the SpanContext of the span currently attached to ctx,
+// if any, so that callers (e.g. tracingUnaryClientInterceptor) can use it as the
+// parent of a child span across an RPC boundary.
+func ExtractSpan(ctx context.Context) (opentracing.SpanContext, error) {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return nil, nil
+	}
+	return span.Context(), nil
+}
+
+// SpanFromContext exposes the raw opentracing.Span stored in ctx so that
+// handlers can add application-level tags/logs without importing opentracing
+// directly in every component.
+func SpanFromContext(ctx context.Context) opentracing.Span {
+	return opentracing.SpanFromContext(ctx)
+}
+
+// InjectToCarrier serializes the SpanContext carried in ctx into carrier
+// (a map[string]string) using opentracing.TextMap, so it can be attached to
+// a protos.Request/protos.Response field for remote calls. Nothing in this
+// repo calls it yet: doing so needs a carrier field on protos.Request (and
+// a matching ExtractFromCarrier call on the server side, see
+// ExtractFromCarrier), and protos isn't owned by this package. Until that's
+// wired up, GRPCClient.Call's "RPC Call" span and the server's "Handler
+// Call" span are each rooted locally — the server does not actually chain
+// off the client's span, despite what ctx plumbing might suggest.
+func InjectToCarrier(ctx context.Context, carrier map[string]string) error {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return nil
+	}
+	return opentracing.GlobalTracer().Inject(
+		span.Context(),
+		opentracing.TextMap,
+		opentracing.TextMapCarrier(carrier),
+	)
+}
+
+// ExtractFromCarrier rebuilds a SpanContext previously serialized with
+// InjectToCarrier. A nil/empty carrier yields a nil SpanContext (root span).
+// Like InjectToCarrier, nothing calls this yet; see its doc comment.
+func ExtractFromCarrier(carrier map[string]string) (opentracing.SpanContext, error) {
+	if len(carrier) == 0 {
+		return nil, nil
+	}
+	sc, err := opentracing.GlobalTracer().Extract(opentracing.TextMap, opentracing.TextMapCarrier(carrier))
+	if err == opentracing.ErrSpanContextNotFound {
+		return nil, nil
+	}
+	return sc, err
+}